@@ -3,17 +3,11 @@
 export GO111MODULE=on
 
 echo "Compile binaries..."
-mkdir -p "${PWD}/bin" && rm -rf ${PWD}/bin/clike_*
+mkdir -p "${PWD}/bin" && rm -rf ${PWD}/bin/clike_* ${PWD}/bin/judged
 go build -o ${PWD}/bin/clike_compiler compiler.go
 go build -o ${PWD}/bin/clike_container container.go
+go build -o ${PWD}/bin/judged judged.go
 
-echo "Enable automatically removing empty cgroups..."
-echo 1 > /sys/fs/cgroup/cpu/notify_on_release
-echo 1 > /sys/fs/cgroup/memory/notify_on_release
-echo 1 > /sys/fs/cgroup/pids/notify_on_release
-
-echo "${PWD}/scripts/clean_cpu_cgroup.sh" > /sys/fs/cgroup/cpu/release_agent
-echo "${PWD}/scripts/clean_memory_cgroup.sh" > /sys/fs/cgroup/memory/release_agent
-echo "${PWD}/scripts/clean_pids_cgroup.sh" > /sys/fs/cgroup/pids/release_agent
-
+# per-run cgroups are removed by the binaries themselves, those left behind by crashed runs
+# are swept by `bin/clike_container gc`, e.g. from cron
 echo "Done!"
@@ -0,0 +1,41 @@
+// +build linux
+// +build go1.15
+
+package checker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Checker decides whether the output of /Main is an acceptable answer for a test case.
+// A non-nil error means the checker itself failed and no verdict could be made.
+type Checker interface {
+	Check(input, output, expected string) (bool, error)
+}
+
+//noinspection GoUnusedExportedFunction
+func New(name string, epsilon float64, specialJudge string) (Checker, error) {
+	switch name {
+	case "exact":
+		return new(exactChecker), nil
+	case "token":
+		return new(tokenChecker), nil
+	case "float":
+		return &floatChecker{epsilon: epsilon}, nil
+	case "spj":
+		if specialJudge == "" {
+			return nil, fmt.Errorf("checker spj requires the path of a special judge binary")
+		}
+		return &specialJudgeChecker{path: specialJudge}, nil
+	default:
+		return nil, fmt.Errorf("unknown checker: %s", name)
+	}
+}
+
+// exactChecker compares the whole output with leading and trailing white space removed
+type exactChecker struct{}
+
+func (c *exactChecker) Check(_, output, expected string) (bool, error) {
+	return strings.TrimSpace(output) == strings.TrimSpace(expected), nil
+}
@@ -0,0 +1,62 @@
+// +build linux
+// +build go1.15
+
+package checker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const specialJudgeTimeout = 10 * time.Second
+
+// specialJudgeChecker runs a trusted checker binary as `<path> <input> <output> <expected>`,
+// all of which are file paths. Exit code 0 means accepted, 1 means wrong answer,
+// anything else is treated as a failure of the checker.
+type specialJudgeChecker struct {
+	path string
+}
+
+func (c *specialJudgeChecker) Check(input, output, expected string) (bool, error) {
+	dir, err := ioutil.TempDir("", "justice-spj-")
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	files := []struct{ name, content string }{
+		{"input", input},
+		{"output", output},
+		{"expected", expected},
+	}
+	args := make([]string, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := ioutil.WriteFile(path, []byte(f.content), 0644); err != nil {
+			return false, err
+		}
+		args = append(args, path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), specialJudgeTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.path, args...)
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err == nil {
+		return true, nil
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok && ctx.Err() == nil && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return false, fmt.Errorf("special judge %s failed, stderr: %s, err: %s", c.path, stderr.String(), err.Error())
+}
@@ -0,0 +1,58 @@
+// +build linux
+// +build go1.15
+
+package checker
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// tokenChecker compares white space separated tokens, ignoring how they are separated
+type tokenChecker struct{}
+
+func (c *tokenChecker) Check(_, output, expected string) (bool, error) {
+	o, e := strings.Fields(output), strings.Fields(expected)
+	if len(o) != len(e) {
+		return false, nil
+	}
+
+	for i := range o {
+		if o[i] != e[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// floatChecker compares tokens like tokenChecker, but tokens which are both numbers
+// only need to be within an absolute or relative error of epsilon
+type floatChecker struct {
+	epsilon float64
+}
+
+func (c *floatChecker) Check(_, output, expected string) (bool, error) {
+	o, e := strings.Fields(output), strings.Fields(expected)
+	if len(o) != len(e) {
+		return false, nil
+	}
+
+	for i := range o {
+		if o[i] == e[i] {
+			continue
+		}
+
+		a, errA := strconv.ParseFloat(o[i], 64)
+		b, errB := strconv.ParseFloat(e[i], 64)
+		if errA != nil || errB != nil || math.IsNaN(a) || math.IsNaN(b) {
+			return false, nil
+		}
+
+		diff := math.Abs(a - b)
+		if diff > c.epsilon && diff > c.epsilon*math.Abs(b) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
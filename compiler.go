@@ -4,43 +4,80 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
-	"os/exec"
-	"syscall"
-	"time"
+	"strings"
+
+	"github.com/justice-oj/sandbox/model"
+	"github.com/justice-oj/sandbox/sandbox"
+	"github.com/justice-oj/sandbox/sandbox/runner"
 )
 
 // compiler wrapper with timeout limitation
-// os.Stderr will not be empty if any error occurred
+// a model.CompileResult is printed on os.Stdout, os.Stderr only carries logs
 func main() {
 	compiler := flag.String("compiler", "/usr/bin/gcc", "C/CPP compiler with abs path")
 	basedir := flag.String("basedir", "/tmp", "basedir of tmp C/CPP code snippet")
 	filename := flag.String("filename", "Main.c", "name of file to be compiled")
-	timeout := flag.Int("timeout", 5000, "compile timeout in milliseconds")
+	defaults := runner.DefaultCompileSpec("")
+	timeout := flag.Int("timeout", defaults.Timeout, "compile timeout in milliseconds")
 	std := flag.String("std", "gnu11", "language standards supported by gcc")
+	language := flag.String("language", "", "language id in -languages, overrides -compiler and -filename")
+	languages := flag.String("languages", "", "JSON language profiles with abs path, see profiles/languages.json")
+	memory := flag.Int64("memory", defaults.Limits.Memory, "memory limitation of the compiler in MB")
+	cpuQuota := flag.Int64("cpu-quota", defaults.Limits.CPUQuota, "CPU time in microseconds per period of 100ms, -1 means unlimited")
+	pids := flag.Int64("pids", defaults.Limits.Pids, "max number of processes and threads of the compiler, -1 means unlimited")
+	toolchain := flag.String("toolchain", strings.Join(sandbox.DefaultToolchain, ","), "comma separated dirs mounted read-only for the compiler")
+	tmpSize := flag.Int64("tmp-size", defaults.TmpSize, "size of /tmp of the compiler in MB")
+	diagnosticsLimit := flag.Int("diagnostics-limit", defaults.DiagnosticsLimit/1024, "diagnostics of the compiler kept in the result in KB")
 	flag.Parse()
 
-	var stdout, stderr bytes.Buffer
-	cmd := exec.Command(*compiler, *filename, "-save-temps", "-std="+*std, "-fmax-errors=10", "-static", "-o", "Main")
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
+	lang := model.CLike(*compiler, *filename)
+	if *language != "" {
+		var err error
+		if lang, err = model.LoadLanguage(*languages, *language); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("model.LoadLanguage(%s, %s) failed, err: %s\n", *languages, *language, err.Error()))
+			writeResult(new(model.CompileResult).GetCompileErrorResult(err.Error()))
+			return
+		}
+		if !flagPassed("std") {
+			*std = lang.Std
+		}
 	}
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	cmd.Dir = *basedir
 
-	time.AfterFunc(time.Duration(*timeout)*time.Millisecond, func() {
-		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
-	})
+	spec := runner.DefaultCompileSpec(*basedir)
+	spec.Command = lang.CompileCommand(*std)
+	spec.Timeout = *timeout
+	spec.Limits.Memory, spec.Limits.CPUQuota, spec.Limits.Pids = *memory, *cpuQuota, *pids
+	spec.Toolchain = strings.Split(*toolchain, ",")
+	spec.TmpSize = *tmpSize
+	spec.DiagnosticsLimit = *diagnosticsLimit * 1024
+	spec.Artifact = lang.Artifact
 
-	if err := cmd.Run(); err != nil {
-		// err.Error() == "signal: killed" means compiler is killed by our timer.
-		_, _ = os.Stderr.WriteString(fmt.Sprintf("stderr: %s, err: %s\n", stderr.String(), err.Error()))
-		return
+	r, err := runner.Compile(context.Background(), spec)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("runner.Compile() failed, err: %s\n", err.Error()))
+		r = new(model.CompileResult).GetCompileErrorResult("sandbox of the compiler failed")
 	}
 
-	_, _ = os.Stdout.WriteString("Compile OK\n")
+	writeResult(r)
+}
+
+func writeResult(r *model.CompileResult) {
+	result, _ := json.Marshal(r)
+	_, _ = os.Stdout.Write(result)
+}
+
+// reports whether the flag was given on the command line rather than left to its default
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
 }
@@ -4,152 +4,201 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
-	"os/exec"
-	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
-	"github.com/docker/docker/pkg/reexec"
+	"github.com/justice-oj/sandbox/checker"
 	"github.com/justice-oj/sandbox/model"
 	"github.com/justice-oj/sandbox/sandbox"
-	"github.com/satori/go.uuid"
+	"github.com/justice-oj/sandbox/sandbox/runner"
 )
 
-func init() {
-	// register "justiceInit" => justiceInit() every time
-	reexec.Register("justiceInit", justiceInit)
-
-	/**
-	* 0. `init()` adds key "justiceInit" in `map`;
-	* 1. reexec.Init() seeks if key `os.Args[0]` exists in `registeredInitializers`;
-	* 2. for the first time this binary is invoked, the key is os.Args[0], AKA "/path/to/clike_container",
-	     which `registeredInitializers` will return `false`;
-	* 3. `main()` calls binary itself by reexec.Command("justiceInit", args...);
-	* 4. for the second time this binary is invoked, the key is os.Args[0], AKA "justiceInit",
-	*    which exists in `registeredInitializers`;
-	* 5. the value `justiceInit()` is invoked, any hooks(like set hostname) before fork() can be placed here.
-	*/
-	if reexec.Init() {
-		os.Exit(0)
+// logs will be printed to os.Stderr
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "gc" {
+		gc(os.Args[2:])
+		return
 	}
-}
 
-func justiceInit() {
-	basedir := os.Args[1]
-	input := os.Args[2]
-	expected := os.Args[3]
-	timeout, _ := strconv.ParseInt(os.Args[4], 10, 32)
+	basedir := flag.String("basedir", "/tmp", "basedir of tmp C binary")
+	input := flag.String("input", "<input>", "test case input")
+	expected := flag.String("expected", "<expected>", "test case expected")
+	inputFile := flag.String("input-file", "", "file of test case input in place of -input, - means stdin")
+	expectedFile := flag.String("expected-file", "", "file of test case expected in place of -expected")
+	defaults := runner.DefaultRunSpec("")
+	timeout := flag.Int64("timeout", defaults.Timeout, "wall time limit in milliseconds")
+	cpuTimeout := flag.Int64("cpu-timeout", 0, "CPU time limit in milliseconds, 0 means only the wall time limit applies")
+	memory := flag.Int64("memory", defaults.Limits.Memory, "memory limitation in MB")
+	cpuQuota := flag.Int64("cpu-quota", defaults.Limits.CPUQuota, "CPU time in microseconds per period, -1 means unlimited")
+	cpuPeriod := flag.Int64("cpu-period", defaults.Limits.CPUPeriod, "CPU period in microseconds")
+	pids := flag.Int64("pids", defaults.Limits.Pids, "max number of processes and threads, -1 means unlimited")
+	kernelMemory := flag.Int64("kernel-memory", defaults.Limits.KernelMemory, "kernel memory limitation in MB, cgroup v1 only")
+	swap := flag.Int64("swap", defaults.Limits.Swap, "swap limitation in MB on top of -memory")
+	cases := flag.String("cases", "", "directory of <name>.in/<name>.out pairs or JSON manifest of test cases, enables batch mode")
+	stopOnFailure := flag.Bool("stop-on-failure", false, "stop at the first test case which is not accepted in batch mode")
+	outputLimit := flag.Int64("output-limit", defaults.OutputLimit, "output limitation of stdout and stderr in KB")
+	checkerName := flag.String("checker", "exact", "output checker: exact, token, float or spj")
+	epsilon := flag.Float64("epsilon", 1e-6, "absolute or relative error allowed by the float checker")
+	specialJudge := flag.String("spj", "", "special judge binary with abs path, invoked as `spj <input> <output> <expected>`")
+	interactor := flag.String("interactor", "", "interactor binary with abs path, invoked as `interactor <input> <expected>`, enables interactive mode")
+	seccompProfile := flag.String("seccomp", "", "JSON seccomp profile with abs path, see profiles/seccomp/default.json")
+	overlay := flag.Bool("overlay", false, "run every test case in a disposable overlay of basedir, which itself stays read-only")
+	overlaySize := flag.Int64("overlay-size", defaults.OverlaySize, "size limitation of the writable layer of -overlay in MB")
+	dev := flag.Bool("dev", false, "mount a minimal read-only /dev with null, zero, full, random and urandom")
+	proc := flag.Bool("proc", false, "mount a procfs of the container with hidepid=2 at /proc")
+	tmpSize := flag.Int64("tmp-size", 0, "size of a tmpfs mounted at /tmp in MB, 0 means no /tmp")
+	uid := flag.Int("uid", 0, "uid /Main runs as inside the container, 0 means root without any capability")
+	gid := flag.Int("gid", 0, "gid /Main runs as inside the container")
+	hostUID := flag.Int("host-uid", 65534, "host uid which -uid is mapped to, takes root to map")
+	hostGID := flag.Int("host-gid", 65534, "host gid which -gid is mapped to, takes root to map")
+	debug := flag.Bool("debug", false, "freeze /Main once it hits a limit and attach its tasks' status, stack and maps to the result")
+	loopback := flag.Bool("loopback", false, "bring lo up in the network namespace of the container, so that /Main can use 127.0.0.1")
+	companion := flag.String("companion", "", "trusted service binary with abs path, started in the network namespace of the container before the first test case, implies -loopback")
+	companionPort := flag.Int("companion-port", 0, "TCP port on 127.0.0.1 which -companion is waited for to listen on, 0 means no waiting")
+	language := flag.String("language", "", "language id in -languages whose run command replaces /Main, runtime dirs of the profile imply -overlay")
+	languages := flag.String("languages", "", "JSON language profiles with abs path, see profiles/languages.json")
+	flag.Parse()
 
-	r := new(model.Result)
-	if err := sandbox.InitNamespace(basedir); err != nil {
-		result, _ := json.Marshal(r.GetRuntimeErrorTaskResult())
-		_, _ = os.Stdout.Write(result)
-		os.Exit(0)
+	batch := *cases != ""
+	testCases := []model.TestCase{{Input: *input, Expected: *expected}}
+	if *inputFile != "" {
+		testCases[0].Input, testCases[0].InputFile = "", *inputFile
+	}
+	if *expectedFile != "" {
+		content, err := ioutil.ReadFile(*expectedFile)
+		if err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("ioutil.ReadFile(%s) failed, err: %s\n", *expectedFile, err.Error()))
+			writeResults(nil, batch)
+			os.Exit(0)
+		}
+		testCases[0].Expected = strings.TrimSpace(string(content))
+	}
+	if batch {
+		var err error
+		if testCases, err = model.LoadTestCases(*cases); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("model.LoadTestCases(%s) failed, err: %s\n", *cases, err.Error()))
+			writeResults(nil, batch)
+			os.Exit(0)
+		}
 	}
 
-	var o, e bytes.Buffer
-	cmd := exec.Command("/Main")
-	cmd.Stdin = strings.NewReader(input)
-	cmd.Stdout = &o
-	cmd.Stderr = &e
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
+	c, err := checker.New(*checkerName, *epsilon, *specialJudge)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("checker.New(%s) failed, err: %s\n", *checkerName, err.Error()))
+		writeResults(nil, batch)
+		os.Exit(0)
 	}
-	cmd.Env = []string{"PS1=[justice] # "}
 
-	time.AfterFunc(time.Duration(timeout)*time.Millisecond, func() {
-		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
-	})
+	command := []string{"/Main"}
+	var runtime []string
+	if *language != "" {
+		lang, err := model.LoadLanguage(*languages, *language)
+		if err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("model.LoadLanguage(%s, %s) failed, err: %s\n", *languages, *language, err.Error()))
+			writeResults(nil, batch)
+			os.Exit(0)
+		}
+		command, runtime = lang.Run, lang.Runtime
+		// runtime dirs are only set up in disposable roots, basedir on the host is left as it is
+		if len(runtime) > 0 {
+			*overlay = true
+		}
 
-	startTime := time.Now().UnixNano() / 1e6
-	if err := cmd.Run(); err != nil {
-		result, _ := json.Marshal(r.GetRuntimeErrorTaskResult())
-		_, _ = os.Stdout.Write(result)
-		_, _ = os.Stderr.WriteString(fmt.Sprintf("err: %s\n", err.Error()))
-		return
+		// flags given on the command line take precedence over the profile
+		if lang.Pids != 0 && !flagPassed("pids") {
+			*pids = lang.Pids
+		}
+		if lang.CPUQuota != 0 && !flagPassed("cpu-quota") {
+			*cpuQuota = lang.CPUQuota
+		}
 	}
-	endTime := time.Now().UnixNano() / 1e6
 
-	if e.Len() > 0 {
-		result, _ := json.Marshal(r.GetRuntimeErrorTaskResult())
-		_, _ = os.Stdout.Write(result)
-		_, _ = os.Stderr.WriteString(fmt.Sprintf("stderr: %s\n", e.String()))
-		return
+	spec := runner.RunSpec{
+		Basedir:       *basedir,
+		Command:       command,
+		Cases:         testCases,
+		StopOnFailure: *stopOnFailure,
+		Timeout:       *timeout,
+		CPUTimeout:    *cpuTimeout,
+		OutputLimit:   *outputLimit,
+		Limits: sandbox.Limits{
+			Memory:       *memory,
+			CPUQuota:     *cpuQuota,
+			CPUPeriod:    *cpuPeriod,
+			Pids:         *pids,
+			KernelMemory: *kernelMemory,
+			Swap:         *swap,
+		},
+		Checker:           c,
+		CheckerNeedsInput: *checkerName == "spj",
+		Interactor:        *interactor,
+		Seccomp:           *seccompProfile,
+		Overlay:           *overlay,
+		OverlaySize:       *overlaySize,
+		Mounts:            sandbox.Mounts{Dev: *dev, Proc: *proc, TmpSize: *tmpSize, Runtime: runtime},
+		UID:               *uid,
+		GID:               *gid,
+		HostUID:           *hostUID,
+		HostGID:           *hostGID,
+		Debug:             *debug,
+		Loopback:          *loopback,
+		Companion:         *companion,
+		CompanionPort:     *companionPort,
+	}
+	results, err := runner.Run(context.Background(), spec)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("runner.Run() failed, err: %s\n", err.Error()))
 	}
+	writeResults(results, batch)
+}
 
-	output := strings.TrimSpace(o.String())
-	if output == expected {
-		// ms, MB
-		timeCost, memoryCost := endTime-startTime, cmd.ProcessState.SysUsage().(*syscall.Rusage).Maxrss/1024
-		// timeCost value 0 will be omitted
-		if timeCost == 0 {
-			timeCost = 1
-		}
+// writes results to os.Stdout, a JSON array in batch mode or a single object otherwise.
+// No results at all means the judge failed before running anything, which is reported as Runtime Error.
+func writeResults(results []*model.Result, batch bool) {
+	if len(results) == 0 {
+		results = append(results, new(model.Result).GetRuntimeErrorTaskResult())
+	}
 
-		result, _ := json.Marshal(r.GetAcceptedTaskResult(timeCost, memoryCost))
-		_, _ = os.Stdout.Write(result)
-	} else {
-		result, _ := json.Marshal(r.GetWrongAnswerTaskResult(input, output, expected))
+	if batch {
+		result, _ := json.Marshal(results)
 		_, _ = os.Stdout.Write(result)
+		return
 	}
 
-	_, _ = os.Stderr.WriteString(fmt.Sprintf("output: %s | expected: %s\n", output, expected))
+	result, _ := json.Marshal(results[0])
+	_, _ = os.Stdout.Write(result)
 }
 
-// logs will be printed to os.Stderr
-func main() {
-	basedir := flag.String("basedir", "/tmp", "basedir of tmp C binary")
-	input := flag.String("input", "<input>", "test case input")
-	expected := flag.String("expected", "<expected>", "test case expected")
-	timeout := flag.String("timeout", "2000", "timeout in milliseconds")
-	memory := flag.String("memory", "256", "memory limitation in MB")
-	flag.Parse()
+// reports whether the flag was given on the command line rather than left to its default
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
 
-	result, u := new(model.Result), uuid.NewV4()
-	if err := sandbox.InitCGroup(strconv.Itoa(os.Getpid()), u.String(), *memory); err != nil {
-		result, _ := json.Marshal(result.GetRuntimeErrorTaskResult())
-		_, _ = os.Stdout.Write(result)
-		os.Exit(0)
-	}
+// `clike_container gc` removes the cgroups left behind by runs which never got to clean up after themselves,
+// printing the IDs of the containers removed on os.Stdout
+func gc(args []string) {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	minAge := flags.Duration("min-age", 10*time.Minute, "only cgroups created at least this long ago are removed, runs younger than that may still be alive")
+	_ = flags.Parse(args)
 
-	cmd := reexec.Command("justiceInit", *basedir, *input, *expected, *timeout, *memory)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Cloneflags: syscall.CLONE_NEWNS |
-			syscall.CLONE_NEWUTS |
-			syscall.CLONE_NEWIPC |
-			syscall.CLONE_NEWPID |
-			syscall.CLONE_NEWNET |
-			syscall.CLONE_NEWUSER,
-		UidMappings: []syscall.SysProcIDMap{
-			{
-				ContainerID: 0,
-				HostID:      os.Getuid(),
-				Size:        1,
-			},
-		},
-		GidMappings: []syscall.SysProcIDMap{
-			{
-				ContainerID: 0,
-				HostID:      os.Getgid(),
-				Size:        1,
-			},
-		},
+	removed, err := sandbox.CollectCGroups(*minAge)
+	for _, id := range removed {
+		_, _ = os.Stdout.WriteString(id + "\n")
 	}
-
-	if err := cmd.Run(); err != nil {
-		result, _ := json.Marshal(result.GetRuntimeErrorTaskResult())
-		_, _ = os.Stderr.WriteString(fmt.Sprintf("%s\n", err.Error()))
-		_, _ = os.Stdout.Write(result)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.CollectCGroups(%s) failed, err: %s\n", *minAge, err.Error()))
+		os.Exit(1)
 	}
-
-	os.Exit(0)
 }
@@ -0,0 +1,278 @@
+// +build linux
+// +build go1.15
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/justice-oj/sandbox/checker"
+	"github.com/justice-oj/sandbox/model"
+	"github.com/justice-oj/sandbox/sandbox"
+	"github.com/justice-oj/sandbox/sandbox/runner"
+)
+
+// judge daemon: accepts model.Job as JSON on POST /judge, compiles and runs it through package runner
+// and replies with model.JobResult. At most -workers jobs run at the same time.
+func main() {
+	listen := flag.String("listen", "unix:/run/justice-sandbox.sock", "unix:<path> or <host>:<port> to listen on")
+	workers := flag.Int("workers", runtime.NumCPU(), "max number of jobs running concurrently")
+	workdir := flag.String("workdir", "/tmp", "dir to create per job working dirs in")
+	languages := flag.String("languages", "", "JSON language profiles with abs path, see profiles/languages.json")
+	compilers := flag.String("compilers", "/usr/bin/gcc,/usr/bin/g++", "comma separated compilers with abs path jobs without a language may choose from, the first one is the default")
+	maxJobSize := flag.Int64("max-job-size", 64, "max size of a job in MB, source and test cases included")
+	maxCompileTimeout := flag.Int64("max-compile-timeout", 10000, "compile timeout in milliseconds jobs are capped at")
+	maxTimeout := flag.Int64("max-timeout", 10000, "wall time limit in milliseconds jobs are capped at")
+	maxMemory := flag.Int64("max-memory", 1024, "memory limitation in MB jobs are capped at")
+	flag.Parse()
+
+	// no slots at all would block every job forever
+	for name, value := range map[string]int64{
+		"workers":             int64(*workers),
+		"max-job-size":        *maxJobSize,
+		"max-compile-timeout": *maxCompileTimeout,
+		"max-timeout":         *maxTimeout,
+		"max-memory":          *maxMemory,
+	} {
+		if value <= 0 {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("-%s must be positive, got %d\n", name, value))
+			os.Exit(1)
+		}
+	}
+
+	listener, err := newListener(*listen)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("newListener(%s) failed, err: %s\n", *listen, err.Error()))
+		os.Exit(1)
+	}
+
+	d := &daemon{
+		slots:             make(chan struct{}, *workers),
+		workdir:           *workdir,
+		languages:         *languages,
+		compilers:         strings.Split(*compilers, ","),
+		maxJobSize:        *maxJobSize * 1024 * 1024,
+		maxCompileTimeout: *maxCompileTimeout,
+		maxTimeout:        *maxTimeout,
+		maxMemory:         *maxMemory,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/judge", d.handleJudge)
+
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("judged listening on %s with %d workers\n", *listen, *workers))
+	if err := http.Serve(listener, mux); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("http.Serve() failed, err: %s\n", err.Error()))
+		os.Exit(1)
+	}
+}
+
+func newListener(address string) (net.Listener, error) {
+	if strings.HasPrefix(address, "unix:") {
+		path := strings.TrimPrefix(address, "unix:")
+		// a stale socket left by a previous run
+		_ = os.Remove(path)
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", address)
+}
+
+type daemon struct {
+	// bounded worker pool, a job holds a slot while it is compiled and run
+	slots     chan struct{}
+	workdir   string
+	languages string
+	// allow-list of Job.Compiler, which is never taken from a client as is
+	compilers []string
+	// in bytes
+	maxJobSize int64
+	// caps of the limits a client asks for, in ms and MB
+	maxCompileTimeout, maxTimeout, maxMemory int64
+}
+
+func (d *daemon) handleJudge(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var job model.Job
+	body := http.MaxBytesReader(w, req.Body, d.maxJobSize)
+	if err := json.NewDecoder(body).Decode(&job); err != nil {
+		http.Error(w, fmt.Sprintf("invalid job: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	lang, err := d.validateJob(&job)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid job: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case d.slots <- struct{}{}:
+		defer func() { <-d.slots }()
+	case <-req.Context().Done():
+		return
+	}
+
+	result, err := d.judge(req.Context(), &job, lang)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("d.judge() failed, err: %s\n", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// checkers a job may choose from, spj would run a binary of the client's choosing
+var jobCheckers = []string{"exact", "token", "float"}
+
+// fills in defaults of clike_compiler and clike_container, returning the profile the job is compiled and run with
+func (d *daemon) validateJob(job *model.Job) (*model.Language, error) {
+	if job.Source == "" {
+		return nil, fmt.Errorf("source is empty")
+	}
+	if len(job.Cases) == 0 {
+		return nil, fmt.Errorf("no test cases")
+	}
+	// an input file would be opened on the host with the privileges of judged
+	for i := range job.Cases {
+		if job.Cases[i].InputFile != "" {
+			return nil, fmt.Errorf("input_file of case %d is not accepted, use input", i)
+		}
+	}
+	var lang *model.Language
+	if job.Language != "" {
+		var err error
+		if lang, err = model.LoadLanguage(d.languages, job.Language); err != nil {
+			return nil, err
+		}
+		// the profile decides how to compile
+		if job.Compiler != "" {
+			return nil, fmt.Errorf("compiler is given by language %s", job.Language)
+		}
+		job.Filename = lang.Source
+		if job.Std == "" {
+			job.Std = lang.Std
+		}
+	} else {
+		if job.Compiler == "" {
+			job.Compiler = d.compilers[0]
+		}
+		if !contains(d.compilers, job.Compiler) {
+			return nil, fmt.Errorf("compiler must be one of %s", strings.Join(d.compilers, ", "))
+		}
+	}
+	if job.Filename == "" {
+		job.Filename = "Main.c"
+	}
+	// the file is created inside the job's basedir
+	if job.Filename != filepath.Base(job.Filename) {
+		return nil, fmt.Errorf("filename must be a plain file name")
+	}
+	if lang == nil {
+		lang = model.CLike(job.Compiler, job.Filename)
+	}
+
+	if job.Std == "" {
+		job.Std = "gnu11"
+	}
+	if job.CompileTimeout <= 0 {
+		job.CompileTimeout = 5000
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = 2000
+	}
+	if job.Memory <= 0 {
+		job.Memory = 256
+	}
+	// a client may ask for less, never for more
+	job.CompileTimeout = min(job.CompileTimeout, d.maxCompileTimeout)
+	job.Timeout = min(job.Timeout, d.maxTimeout)
+	job.Memory = min(job.Memory, d.maxMemory)
+	if job.Checker == "" {
+		job.Checker = "exact"
+	}
+	if !contains(jobCheckers, job.Checker) {
+		return nil, fmt.Errorf("checker must be one of %s", strings.Join(jobCheckers, ", "))
+	}
+	return lang, nil
+}
+
+func min(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// compiles and runs a job in a working dir of its own, which becomes the root of the container
+func (d *daemon) judge(ctx context.Context, job *model.Job, lang *model.Language) (*model.JobResult, error) {
+	basedir, err := ioutil.TempDir(d.workdir, "justice-job-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(basedir) }()
+
+	if err := ioutil.WriteFile(filepath.Join(basedir, job.Filename), []byte(job.Source), 0644); err != nil {
+		return nil, err
+	}
+
+	compileSpec := runner.DefaultCompileSpec(basedir)
+	compileSpec.Command = lang.CompileCommand(job.Std)
+	compileSpec.Timeout = int(job.CompileTimeout)
+	compileSpec.Artifact = lang.Artifact
+	compileResult, err := runner.Compile(ctx, compileSpec)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.JobResult{Compile: compileResult}
+	if compileResult.Status != model.CompileStatusOk {
+		return result, nil
+	}
+
+	// validated by validateJob
+	c, err := checker.New(job.Checker, 1e-6, "")
+	if err != nil {
+		return nil, err
+	}
+	runSpec := runner.DefaultRunSpec(basedir)
+	runSpec.Command = lang.Run
+	runSpec.Cases = job.Cases
+	runSpec.StopOnFailure = job.StopOnFailure
+	runSpec.Timeout = job.Timeout
+	runSpec.Limits.Memory = job.Memory
+	runSpec.Checker = c
+	runSpec.Mounts = sandbox.Mounts{Runtime: lang.Runtime}
+	runSpec.Overlay = len(lang.Runtime) > 0
+	if lang.Pids != 0 {
+		runSpec.Limits.Pids = lang.Pids
+	}
+	if lang.CPUQuota != 0 {
+		runSpec.Limits.CPUQuota = lang.CPUQuota
+	}
+	if result.Results, err = runner.Run(ctx, runSpec); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,51 @@
+// +build linux
+// +build go1.15
+
+package model
+
+// CompileResult is what clike_compiler prints on os.Stdout
+type CompileResult struct {
+	// wall time of the compiler in ms
+	Runtime int64 `json:"runtime,omitempty"`
+	// peak RSS of the compiler and its children in MB
+	Memory int64  `json:"memory,omitempty"`
+	Status int32  `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// stderr of the compiler, truncated to the limit of clike_compiler
+	Diagnostics string `json:"diagnostics,omitempty"`
+	// -1 if the compiler did not exit by itself
+	ExitCode int `json:"exit_code"`
+}
+
+const (
+	CompileStatusOk = iota
+	CompileStatusError
+	CompileStatusTimeout
+	CompileStatusMemoryExceeded
+)
+
+func (r *CompileResult) GetCompileOKResult() *CompileResult {
+	r.Status = CompileStatusOk
+	r.Error = ""
+	return r
+}
+
+func (r *CompileResult) GetCompileErrorResult(diagnostics string) *CompileResult {
+	r.Status = CompileStatusError
+	r.Error = "Compile Error"
+	r.Diagnostics = diagnostics
+	return r
+}
+
+func (r *CompileResult) GetCompileTimeoutResult() *CompileResult {
+	r.Status = CompileStatusTimeout
+	r.Error = "Compile Timeout"
+	r.ExitCode = -1
+	return r
+}
+
+func (r *CompileResult) GetCompilerMemoryExceededResult() *CompileResult {
+	r.Status = CompileStatusMemoryExceeded
+	r.Error = "Compiler Memory Exceeded"
+	return r
+}
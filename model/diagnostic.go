@@ -0,0 +1,26 @@
+// +build linux
+// +build go1.15
+
+package model
+
+// Diagnostic is recorded in debug mode once /Main hits a limit: every task it left is frozen
+// and inspected right before they are all killed
+type Diagnostic struct {
+	// the limit hit, "timeout", "cpu timeout" or "output limit"
+	Limit string         `json:"limit"`
+	Tasks []TaskSnapshot `json:"tasks,omitempty"`
+	// why the tasks could not be frozen or listed
+	Error string `json:"error,omitempty"`
+}
+
+// TaskSnapshot holds /proc/<pid>/status, stack and maps of a frozen task
+type TaskSnapshot struct {
+	// as seen inside the container
+	Pid    int    `json:"pid"`
+	Status string `json:"status,omitempty"`
+	// kernel stack, only readable with CAP_SYS_ADMIN in the initial user namespace
+	Stack string `json:"stack,omitempty"`
+	Maps  string `json:"maps,omitempty"`
+	// files which could not be read
+	Errors []string `json:"errors,omitempty"`
+}
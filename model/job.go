@@ -0,0 +1,34 @@
+// +build linux
+// +build go1.15
+
+package model
+
+// Job is a compile-and-run request accepted by judged, which rejects cases with an InputFile
+type Job struct {
+	// source code of the submission
+	Source string `json:"source"`
+	// language id in the language profiles of judged, overrides Compiler and Filename
+	Language string `json:"language,omitempty"`
+	// compiler with abs path out of the -compilers of judged, e.g. /usr/bin/gcc
+	Compiler string `json:"compiler"`
+	// name of the source file, e.g. Main.c
+	Filename string `json:"filename"`
+	// language standard supported by gcc, e.g. gnu11
+	Std string `json:"std"`
+	// compile timeout in ms, capped at -max-compile-timeout of judged
+	CompileTimeout int64 `json:"compile_timeout"`
+	// wall time limit in ms, capped at -max-timeout of judged
+	Timeout int64 `json:"timeout"`
+	// memory limitation in MB, capped at -max-memory of judged
+	Memory int64 `json:"memory"`
+	// checker name, one of exact, token and float, see clike_container -checker
+	Checker       string     `json:"checker,omitempty"`
+	StopOnFailure bool       `json:"stop_on_failure,omitempty"`
+	Cases         []TestCase `json:"cases"`
+}
+
+// JobResult carries the result of the compiler, followed by one Result per test case if compiling succeeded
+type JobResult struct {
+	Compile *CompileResult `json:"compile"`
+	Results []*Result      `json:"results,omitempty"`
+}
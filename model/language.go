@@ -0,0 +1,75 @@
+// +build linux
+// +build go1.15
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Language describes how a submission is compiled by clike_compiler and run by clike_container
+type Language struct {
+	// name of the source file in basedir, e.g. Main.c
+	Source string `json:"source"`
+	// language standard substituted for {std} unless given by -std
+	Std string `json:"std,omitempty"`
+	// compile command run in basedir, {source} and {std} are substituted. Empty means nothing to compile
+	Compile []string `json:"compile,omitempty"`
+	// file left in basedir by a successful compilation, e.g. Main or Main.class
+	Artifact string `json:"artifact"`
+	// command run inside the container in place of /Main, paths are relative to the container root
+	Run []string `json:"run"`
+	// dirs of the host mounted read-only at the same path in the container, e.g. an interpreter or a JVM, implies -overlay
+	Runtime []string `json:"runtime,omitempty"`
+	// limitations replacing the defaults of clike_container unless given by flags, 0 keeps the default
+	Pids     int64 `json:"pids,omitempty"`
+	CPUQuota int64 `json:"cpu_quota,omitempty"`
+}
+
+// CLike is the profile clike_compiler and clike_container fall back to without a language id:
+// compiler builds filename into a static /Main
+//noinspection GoUnusedExportedFunction
+func CLike(compiler, filename string) *Language {
+	return &Language{
+		Source:   filename,
+		Compile:  []string{compiler, "{source}", "-save-temps", "-std={std}", "-fmax-errors=10", "-static", "-o", "Main"},
+		Artifact: "Main",
+		Run:      []string{"/Main"},
+	}
+}
+
+// LoadLanguage looks up language id in a JSON file of profiles keyed by language id,
+// see profiles/languages.json
+func LoadLanguage(path, id string) (*Language, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var languages map[string]*Language
+	if err := json.Unmarshal(content, &languages); err != nil {
+		return nil, err
+	}
+
+	l, ok := languages[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown language: %s", id)
+	}
+	if l.Source == "" || l.Artifact == "" || len(l.Run) == 0 {
+		return nil, fmt.Errorf("language %s requires source, artifact and run", id)
+	}
+	return l, nil
+}
+
+// CompileCommand returns the compile command with placeholders substituted
+func (l *Language) CompileCommand(std string) []string {
+	r := strings.NewReplacer("{source}", l.Source, "{std}", std)
+	command := make([]string, 0, len(l.Compile))
+	for _, arg := range l.Compile {
+		command = append(command, r.Replace(arg))
+	}
+	return command
+}
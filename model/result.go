@@ -4,13 +4,39 @@
 package model
 
 type Result struct {
-	Runtime  int64  `json:"runtime,omitempty"`
+	// CPU time in ms
+	Runtime int64 `json:"runtime,omitempty"`
+	// wall time in ms
+	WallTime int64  `json:"wall_time,omitempty"`
 	Memory   int64  `json:"memory,omitempty"`
 	Status   int32  `json:"status"`
 	Error    string `json:"error,omitempty"`
 	Input    string `json:"input,omitempty"`
 	Output   string `json:"output,omitempty"`
 	Expected string `json:"expected,omitempty"`
+	// exit code of /Main, -1 if it was terminated by Signal or never ran
+	ExitCode int    `json:"exit_code"`
+	Signal   string `json:"signal,omitempty"`
+	// sub-reason of a Runtime Error, e.g. "division by zero"
+	Reason string `json:"reason,omitempty"`
+	Usage  *Usage `json:"usage,omitempty"`
+	// only in debug mode when a limit is hit
+	Diagnostic *Diagnostic `json:"diagnostic,omitempty"`
+}
+
+// Usage is the resource usage of a single run of /Main, reported for every verdict
+type Usage struct {
+	// in ms
+	UserTime int64 `json:"user_time"`
+	SysTime  int64 `json:"sys_time"`
+	// peak RSS of /Main and the children it has waited for, in KB
+	MaxRSS int64 `json:"max_rss"`
+	// peak memory usage of the container's cgroup in KB, 0 if unknown
+	CGroupMaxMemory            int64 `json:"cgroup_max_memory"`
+	VoluntaryContextSwitches   int64 `json:"voluntary_context_switches"`
+	InvoluntaryContextSwitches int64 `json:"involuntary_context_switches"`
+	MinorPageFaults            int64 `json:"minor_page_faults"`
+	MajorPageFaults            int64 `json:"major_page_faults"`
 }
 
 const (
@@ -18,8 +44,10 @@ const (
 	_
 	StatusRe
 	StatusTle
-	_
+	StatusMle
 	StatusWa
+	StatusOle
+	StatusRf
 )
 
 func (r *Result) GetAcceptedTaskResult(runtime, memory int64) *Result {
@@ -35,9 +63,28 @@ func (r *Result) GetRuntimeErrorTaskResult() *Result {
 	return r
 }
 
-func (r *Result) GetTimeLimitExceededErrorTaskResult() *Result {
+func (r *Result) GetTimeLimitExceededErrorTaskResult(runtime int64) *Result {
 	r.Status = StatusTle
-	r.Error = "Runtime Error"
+	r.Runtime = runtime
+	r.Error = "Time Limit Exceeded"
+	return r
+}
+
+func (r *Result) GetMemoryLimitExceededErrorTaskResult() *Result {
+	r.Status = StatusMle
+	r.Error = "Memory Limit Exceeded"
+	return r
+}
+
+func (r *Result) GetOutputLimitExceededErrorTaskResult() *Result {
+	r.Status = StatusOle
+	r.Error = "Output Limit Exceeded"
+	return r
+}
+
+func (r *Result) GetRestrictedFunctionErrorTaskResult() *Result {
+	r.Status = StatusRf
+	r.Error = "Restricted Function"
 	return r
 }
 
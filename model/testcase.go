@@ -0,0 +1,69 @@
+// +build linux
+// +build go1.15
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type TestCase struct {
+	Input string `json:"input"`
+	// file holding the input, streamed into /Main in place of Input. "-" means stdin of clike_container
+	InputFile string `json:"input_file,omitempty"`
+	Expected  string `json:"expected"`
+}
+
+// LoadTestCases reads test cases from either
+//   - a directory holding `<name>.in` / `<name>.out` pairs, ordered by name, or
+//   - a JSON manifest file: [{"input": "...", "expected": "..."}, ...]
+//
+// Inputs of a directory are left on disk, as are the `input_file`s of a manifest,
+// which are relative to the manifest itself.
+func LoadTestCases(path string) ([]TestCase, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		var cases []TestCase
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, &cases); err != nil {
+			return nil, err
+		}
+		for i := range cases {
+			if cases[i].InputFile != "" && !filepath.IsAbs(cases[i].InputFile) {
+				cases[i].InputFile = filepath.Join(filepath.Dir(path), cases[i].InputFile)
+			}
+			cases[i].Expected = strings.TrimSpace(cases[i].Expected)
+		}
+		return cases, nil
+	}
+
+	inputs, err := filepath.Glob(filepath.Join(path, "*.in"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(inputs)
+
+	cases := make([]TestCase, 0, len(inputs))
+	for _, in := range inputs {
+		out := strings.TrimSuffix(in, ".in") + ".out"
+		expected, err := ioutil.ReadFile(out)
+		if err != nil {
+			return nil, fmt.Errorf("expected output of %s: %s", in, err.Error())
+		}
+		cases = append(cases, TestCase{InputFile: in, Expected: strings.TrimSpace(string(expected))})
+	}
+	return cases, nil
+}
@@ -0,0 +1,75 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// https://man7.org/linux/man-pages/man7/capabilities.7.html
+const (
+	prCapBSetDrop        = 24
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+
+	linuxCapabilityVersion3 = 0x20080522
+)
+
+type capUserHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capUserData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+// DropPrivileges switches the calling thread to uid and gid inside the user namespace and leaves it without any
+// capability, neither now nor after execve(2), which cannot grant new privileges either.
+// Same as SeccompFilter.Install, it must be called on a locked OS thread which forks /Main and is thrown away afterwards,
+// the raw syscalls below only affect the calling thread.
+//noinspection GoUnusedExportedFunction
+func DropPrivileges(uid, gid int) error {
+	// the bounding set caps what execve(2) grants to uid 0, dropping from it takes CAP_SETPCAP, so it goes first
+	for c := uintptr(0); ; c++ {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapBSetDrop, c, 0); errno == syscall.EINVAL {
+			break
+		} else if errno != 0 {
+			return fmt.Errorf("prctl(PR_CAPBSET_DROP, %d) failed, err: %s", c, errno.Error())
+		}
+	}
+
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("prctl(PR_CAP_AMBIENT_CLEAR_ALL) failed, err: %s", errno.Error())
+	}
+
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS) failed, err: %s", errno.Error())
+	}
+
+	// supplementary groups go along with uid 0, they are mapped to those of the host
+	if uid != 0 || gid != 0 {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SETGROUPS, 0, 0, 0); errno != 0 {
+			return fmt.Errorf("setgroups(0) failed, err: %s", errno.Error())
+		}
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SETRESGID, uintptr(gid), uintptr(gid), uintptr(gid)); errno != 0 {
+			return fmt.Errorf("setresgid(%d) failed, err: %s", gid, errno.Error())
+		}
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SETRESUID, uintptr(uid), uintptr(uid), uintptr(uid)); errno != 0 {
+			return fmt.Errorf("setresuid(%d) failed, err: %s", uid, errno.Error())
+		}
+	}
+
+	// a non-zero uid has lost the effective and permitted sets already, the inheritable set stays until cleared
+	header := capUserHeader{version: linuxCapabilityVersion3}
+	data := [2]capUserData{}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("capset() failed, err: %s", errno.Error())
+	}
+	return nil
+}
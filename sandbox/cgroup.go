@@ -8,20 +8,93 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 const (
-	cgCPUPathPrefix    = "/sys/fs/cgroup/cpu/"
-	cgPidPathPrefix    = "/sys/fs/cgroup/pids/"
-	cgMemoryPathPrefix = "/sys/fs/cgroup/memory/"
+	cgCPUPathPrefix     = "/sys/fs/cgroup/cpu/"
+	cgCPUAcctPathPrefix = "/sys/fs/cgroup/cpuacct/"
+	cgPidPathPrefix     = "/sys/fs/cgroup/pids/"
+	cgMemoryPathPrefix  = "/sys/fs/cgroup/memory/"
+	cgFreezerPathPrefix = "/sys/fs/cgroup/freezer/"
+	cgUnifiedPath       = "/sys/fs/cgroup/"
+
+	// CGROUP2_SUPER_MAGIC, see statfs(2)
+	cgroup2SuperMagic = 0x63677270
 )
 
+// Limits are the resource limits applied to the cgroup of a container
+type Limits struct {
+	// memory limitation in MB
+	Memory int64
+	// CPU time in microseconds the container may use per CPUPeriod
+	CPUQuota  int64
+	CPUPeriod int64
+	// max number of tasks, -1 means unlimited
+	Pids int64
+	// kernel memory limitation in MB, v1 only
+	KernelMemory int64
+	// swap limitation in MB on top of Memory
+	Swap int64
+}
+
+// DefaultLimits returns the limits which used to be hard-coded: 10% of a CPU, 64 tasks, 64MB kernel memory and no swap
 //noinspection GoUnusedExportedFunction
-func InitCGroup(pid, containerID, memory string) error {
-	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitCGroup(%s, %s, %s) starting...\n", pid, containerID, memory))
+func DefaultLimits(memory int64) Limits {
+	return Limits{
+		Memory:       memory,
+		CPUQuota:     10000,
+		CPUPeriod:    100000,
+		Pids:         64,
+		KernelMemory: 64,
+		Swap:         0,
+	}
+}
+
+// Validate reports limits the cgroups of a container cannot be created with, e.g. the zero value
+func (l Limits) Validate() error {
+	switch {
+	case l.Memory <= 0:
+		return fmt.Errorf("memory limitation must be positive, got %d", l.Memory)
+	case l.CPUPeriod <= 0:
+		return fmt.Errorf("CPU period must be positive, got %d", l.CPUPeriod)
+	case l.CPUQuota == 0 || l.CPUQuota < -1:
+		return fmt.Errorf("CPU quota must be positive or -1, got %d", l.CPUQuota)
+	case l.Pids == 0 || l.Pids < -1:
+		return fmt.Errorf("max number of tasks must be positive or -1, got %d", l.Pids)
+	case l.KernelMemory < 0 || l.Swap < 0:
+		return fmt.Errorf("kernel memory and swap limitations must not be negative")
+	}
+	return nil
+}
+
+// IsCGroupV2 reports whether /sys/fs/cgroup is mounted as the unified (v2) hierarchy.
+// Hybrid setups which mount cgroup2 at /sys/fs/cgroup/unified are treated as v1.
+func IsCGroupV2() bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(cgUnifiedPath, &st); err != nil {
+		return false
+	}
+	return st.Type == cgroup2SuperMagic
+}
+
+// InitCGroup creates the cgroups of containerID with limits and moves every thread of pid into them,
+// justiceInit and justiceCompile join theirs after the Go runtime has started its threads
+//noinspection GoUnusedExportedFunction
+func InitCGroup(pid, containerID string, limits Limits) error {
+	if IsCGroupV2() {
+		return initCGroupV2(pid, containerID, limits)
+	}
+
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitCGroup(%s, %s, %+v) starting...\n", pid, containerID, limits))
 
 	dirs := []string{
 		filepath.Join(cgCPUPathPrefix, containerID),
+		filepath.Join(cgCPUAcctPathPrefix, containerID),
 		filepath.Join(cgPidPathPrefix, containerID),
 		filepath.Join(cgMemoryPathPrefix, containerID),
 	}
@@ -33,37 +106,46 @@ func InitCGroup(pid, containerID, memory string) error {
 		}
 	}
 
-	if err := cpuCGroup(pid, containerID); err != nil {
+	if err := cpuCGroup(pid, containerID, limits); err != nil {
 		_, _ = os.Stderr.WriteString(fmt.Sprintf("cpuCGroup(%s, %s) failed, err: %s\n", pid, containerID, err.Error()))
 		return err
 	}
 
-	if err := pidCGroup(pid, containerID); err != nil {
+	if err := cpuAcctCGroup(pid, containerID); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("cpuAcctCGroup(%s, %s) failed, err: %s\n", pid, containerID, err.Error()))
+		return err
+	}
+
+	if err := pidCGroup(pid, containerID, limits); err != nil {
 		_, _ = os.Stderr.WriteString(fmt.Sprintf("pidCGroup(%s, %s) failed, err: %s\n", pid, containerID, err.Error()))
 		return err
 	}
 
-	if err := memoryCGroup(pid, containerID, memory); err != nil {
+	if err := memoryCGroup(pid, containerID, limits); err != nil {
 		_, _ = os.Stderr.WriteString(fmt.Sprintf("memoryCGroup(%s, %s) failed, err: %s\n", pid, containerID, err.Error()))
 		return err
 	}
 
-	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitCGroup(%s, %s, %s) done\n", pid, containerID, memory))
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitCGroup(%s, %s, %+v) done\n", pid, containerID, limits))
 	return nil
 }
 
-// https://www.kernel.org/doc/Documentation/scheduler/sched-bwc.txt
-func cpuCGroup(pid, containerID string) error {
-	cgCPUPath := filepath.Join(cgCPUPathPrefix, containerID)
-	mapping := map[string]string{
-		"tasks":            pid,
-		"cpu.cfs_quota_us": "10000",
-	}
+// cgroupFile is a control file to be written in order, optional ones are skipped if the kernel lacks them
+type cgroupFile struct {
+	name     string
+	value    string
+	optional bool
+}
 
-	for key, value := range mapping {
-		path := filepath.Join(cgCPUPath, key)
-		if err := ioutil.WriteFile(path, []byte(value), 0644); err != nil {
-			_, _ = os.Stderr.WriteString(fmt.Sprintf("Writing [%s] to file: %s failed\n", value, path))
+func writeCGroupFiles(dir string, files []cgroupFile) error {
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if _, err := os.Stat(path); f.optional && os.IsNotExist(err) {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("%s does not exist, skipped\n", path))
+			continue
+		}
+		if err := ioutil.WriteFile(path, []byte(f.value), 0644); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("Writing [%s] to file: %s failed\n", f.value, path))
 			return err
 		}
 		c, _ := ioutil.ReadFile(path)
@@ -72,43 +154,155 @@ func cpuCGroup(pid, containerID string) error {
 	return nil
 }
 
+// https://www.kernel.org/doc/Documentation/scheduler/sched-bwc.txt
+func cpuCGroup(pid, containerID string, limits Limits) error {
+	// the period goes first, the quota is validated against it
+	return writeCGroupFiles(filepath.Join(cgCPUPathPrefix, containerID), []cgroupFile{
+		{name: "cpu.cfs_period_us", value: strconv.FormatInt(limits.CPUPeriod, 10)},
+		{name: "cpu.cfs_quota_us", value: strconv.FormatInt(limits.CPUQuota, 10)},
+		{name: "cgroup.procs", value: pid},
+	})
+}
+
+// https://www.kernel.org/doc/Documentation/cgroup-v1/cpuacct.txt
+func cpuAcctCGroup(pid, containerID string) error {
+	return writeCGroupFiles(filepath.Join(cgCPUAcctPathPrefix, containerID), []cgroupFile{
+		{name: "cgroup.procs", value: pid},
+	})
+}
+
 // https://www.kernel.org/doc/Documentation/cgroup-v1/pids.txt
-func pidCGroup(pid, containerID string) error {
-	cgPidPath := filepath.Join(cgPidPathPrefix, containerID)
-	mapping := map[string]string{
-		"cgroup.procs": pid,
-		"pids.max":     "64",
+func pidCGroup(pid, containerID string, limits Limits) error {
+	return writeCGroupFiles(filepath.Join(cgPidPathPrefix, containerID), []cgroupFile{
+		{name: "pids.max", value: pidsMax(limits)},
+		{name: "cgroup.procs", value: pid},
+	})
+}
+
+// value of pids.max, which takes "max" rather than a negative number for unlimited on both v1 and v2
+func pidsMax(limits Limits) string {
+	if limits.Pids < 0 {
+		return "max"
 	}
+	return strconv.FormatInt(limits.Pids, 10)
+}
 
-	for key, value := range mapping {
-		path := filepath.Join(cgPidPath, key)
-		if err := ioutil.WriteFile(path, []byte(value), 0644); err != nil {
-			_, _ = os.Stderr.WriteString(fmt.Sprintf("Writing [%s] to file: %s failed\n", value, path))
+// https://www.kernel.org/doc/Documentation/cgroup-v1/memory.txt
+func memoryCGroup(pid, containerID string, limits Limits) error {
+	// kmem must be limited before any task joins, memsw must not be lower than limit_in_bytes.
+	// Both are absent on kernels without kmem or swap accounting.
+	return writeCGroupFiles(filepath.Join(cgMemoryPathPrefix, containerID), []cgroupFile{
+		{name: "memory.kmem.limit_in_bytes", value: fmt.Sprintf("%dm", limits.KernelMemory), optional: true},
+		{name: "memory.limit_in_bytes", value: fmt.Sprintf("%dm", limits.Memory)},
+		{name: "memory.memsw.limit_in_bytes", value: fmt.Sprintf("%dm", limits.Memory+limits.Swap), optional: true},
+		{name: "cgroup.procs", value: pid},
+	})
+}
+
+// names of the cgroups created by InitCGroup, which are named after the UUID of the container
+var containerIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// roots of the hierarchies the cgroups of a container live in
+func cgroupHierarchies() []string {
+	if IsCGroupV2() {
+		return []string{cgUnifiedPath}
+	}
+	// the freezer one only holds the child of KillGroup
+	return []string{cgCPUPathPrefix, cgCPUAcctPathPrefix, cgPidPathPrefix, cgMemoryPathPrefix, cgFreezerPathPrefix}
+}
+
+// RemoveCGroup moves the calling process back to the root cgroups if it is still in those of containerID,
+// kills whatever is left in them and removes them. A cgroup which does not exist is not an error.
+//noinspection GoUnusedExportedFunction
+func RemoveCGroup(containerID string) error {
+	self := strconv.Itoa(os.Getpid())
+	for _, root := range cgroupHierarchies() {
+		dir := filepath.Join(root, containerID)
+		if err := removeCGroupDir(root, dir, self); err != nil && !os.IsNotExist(err) {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("removeCGroupDir(%s) failed, err: %s\n", dir, err.Error()))
 			return err
 		}
-		c, _ := ioutil.ReadFile(path)
-		_, _ = os.Stderr.WriteString(fmt.Sprintf("Content of %s is: %s", path, c))
 	}
 	return nil
 }
 
-// https://www.kernel.org/doc/Documentation/cgroup-v1/memory.txt
-func memoryCGroup(pid, containerID, memory string) error {
-	cgMemoryPath := filepath.Join(cgMemoryPathPrefix, containerID)
-	mapping := map[string]string{
-		"memory.kmem.limit_in_bytes": "64m",
-		"tasks":                      pid,
-		"memory.limit_in_bytes":      fmt.Sprintf("%sm", memory),
-	}
-
-	for key, value := range mapping {
-		path := filepath.Join(cgMemoryPath, key)
-		if err := ioutil.WriteFile(path, []byte(value), 0644); err != nil {
-			_, _ = os.Stderr.WriteString(fmt.Sprintf("Writing [%s] to file: %s failed\n", value, path))
+func removeCGroupDir(root, dir, self string) error {
+	// children go first, e.g. the one of KillGroup
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if err := removeCGroupDir(root, filepath.Join(dir, entry.Name()), self); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	// rmdir(2) fails with EBUSY until every task is gone, killed ones included
+	deadline := time.Now().Add(time.Second)
+	for {
+		pids, err := cgroupProcs(dir)
+		if err != nil {
 			return err
 		}
-		c, _ := ioutil.ReadFile(path)
-		_, _ = os.Stderr.WriteString(fmt.Sprintf("Content of %s is: %s", path, c))
+		for _, pid := range pids {
+			if pid == self {
+				if err := ioutil.WriteFile(filepath.Join(root, "cgroup.procs"), []byte(self), 0644); err != nil {
+					return err
+				}
+				continue
+			}
+			if p, err := strconv.Atoi(pid); err == nil {
+				_ = syscall.Kill(p, syscall.SIGKILL)
+			}
+		}
+
+		err = syscall.Rmdir(dir)
+		if err == nil || err == syscall.ENOENT {
+			return nil
+		}
+		if err != syscall.EBUSY || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
-	return nil
+}
+
+func cgroupProcs(dir string) ([]string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(content)), nil
+}
+
+// CollectCGroups removes the cgroups of containers created at least minAge ago, which are left behind
+// by runs which crashed or were killed before RemoveCGroup. The IDs of the containers removed are returned.
+//noinspection GoUnusedExportedFunction
+func CollectCGroups(minAge time.Duration) ([]string, error) {
+	var removed []string
+	seen := make(map[string]bool)
+	for _, root := range cgroupHierarchies() {
+		entries, err := ioutil.ReadDir(root)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		for _, entry := range entries {
+			id := entry.Name()
+			if !entry.IsDir() || !containerIDPattern.MatchString(id) || seen[id] || time.Since(entry.ModTime()) < minAge {
+				continue
+			}
+			seen[id] = true
+			if err := RemoveCGroup(id); err != nil {
+				return removed, err
+			}
+			removed = append(removed, id)
+		}
+	}
+	return removed, nil
 }
@@ -0,0 +1,82 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// https://www.kernel.org/doc/Documentation/admin-guide/cgroup-v2.rst
+func initCGroupV2(pid, containerID string, limits Limits) error {
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("initCGroupV2(%s, %s, %+v) starting...\n", pid, containerID, limits))
+
+	// controllers must be enabled in the parent before they show up in the child group
+	if err := enableControllers(cgUnifiedPath, []string{"cpu", "pids", "memory"}); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(cgUnifiedPath, containerID)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("os.MkdirAll(%s, os.ModePerm) failed, err: %s\n", dir, err.Error()))
+		return err
+	}
+
+	// limits must be in place before the process joins the group, so cgroup.procs goes last.
+	// There is no separate kernel memory limit in v2, it is charged to memory.max.
+	// memory.swap.max is absent when the kernel is booted without swap accounting.
+	quota := strconv.FormatInt(limits.CPUQuota, 10)
+	if limits.CPUQuota < 0 {
+		quota = "max"
+	}
+	err := writeCGroupFiles(dir, []cgroupFile{
+		{name: "cpu.max", value: fmt.Sprintf("%s %d", quota, limits.CPUPeriod)},
+		{name: "pids.max", value: pidsMax(limits)},
+		{name: "memory.max", value: strconv.FormatInt(limits.Memory*1024*1024, 10)},
+		{name: "memory.swap.max", value: strconv.FormatInt(limits.Swap*1024*1024, 10), optional: true},
+		{name: "cgroup.procs", value: pid},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("initCGroupV2(%s, %s, %+v) done\n", pid, containerID, limits))
+	return nil
+}
+
+// enables the controllers missing from cgroup.subtree_control of dir. Writing it at all fails with EBUSY
+// once dir holds processes, e.g. the root of a delegated or namespaced cgroup, even for controllers already enabled.
+func enableControllers(dir string, controllers []string) error {
+	subtreeControl := filepath.Join(dir, "cgroup.subtree_control")
+	content, err := ioutil.ReadFile(subtreeControl)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("ioutil.ReadFile(%s) failed, err: %s\n", subtreeControl, err.Error()))
+		return err
+	}
+
+	enabled := make(map[string]bool)
+	for _, controller := range strings.Fields(string(content)) {
+		enabled[controller] = true
+	}
+	var missing []string
+	for _, controller := range controllers {
+		if !enabled[controller] {
+			missing = append(missing, "+"+controller)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	value := strings.Join(missing, " ")
+	if err := ioutil.WriteFile(subtreeControl, []byte(value), 0644); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("Writing [%s] to file: %s failed\n", value, subtreeControl))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// CPUUsage reads the CPU time consumed by all tasks in the cgroup of a container,
+// including children which have not been waited for yet.
+type CPUUsage struct {
+	stat *cgroupStat
+	// duration of one unit read from stat
+	unit time.Duration
+}
+
+//noinspection GoUnusedExportedFunction
+func NewCPUUsage(containerID string) (*CPUUsage, error) {
+	return newCPUUsage(containerID)
+}
+
+// cgroup is relative to the root of the hierarchy
+func newCPUUsage(cgroup string) (*CPUUsage, error) {
+	if IsCGroupV2() {
+		stat, err := openCGroupStat(filepath.Join(cgUnifiedPath, cgroup, "cpu.stat"), "usage_usec")
+		if err != nil {
+			return nil, err
+		}
+		return &CPUUsage{stat: stat, unit: time.Microsecond}, nil
+	}
+
+	// https://www.kernel.org/doc/Documentation/cgroup-v1/cpuacct.txt
+	stat, err := openCGroupStat(filepath.Join(cgCPUAcctPathPrefix, cgroup, "cpuacct.usage"), "")
+	if err != nil {
+		return nil, err
+	}
+	return &CPUUsage{stat: stat, unit: time.Nanosecond}, nil
+}
+
+func (c *CPUUsage) Usage() (time.Duration, error) {
+	value, err := c.stat.read()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(value) * c.unit, nil
+}
+
+func (c *CPUUsage) Close() error {
+	return c.stat.close()
+}
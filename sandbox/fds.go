@@ -0,0 +1,57 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// SendFiles passes open files to the process at the other end of the unix socket sock
+//noinspection GoUnusedExportedFunction
+func SendFiles(sock *os.File, files ...*os.File) error {
+	fds := make([]int, 0, len(files))
+	for _, f := range files {
+		fds = append(fds, int(f.Fd()))
+	}
+
+	// at least one byte of normal data has to go along with the ancillary data
+	return syscall.Sendmsg(int(sock.Fd()), []byte{0}, syscall.UnixRights(fds...), nil, 0)
+}
+
+// ReceiveFiles receives exactly n open files sent by SendFiles
+//noinspection GoUnusedExportedFunction
+func ReceiveFiles(sock *os.File, n int) ([]*os.File, error) {
+	buf, oob := make([]byte, 1), make([]byte, syscall.CmsgSpace(n*4))
+	// received files must not leak into processes exec'ed later on
+	_, oobn, _, _, err := syscall.Recvmsg(int(sock.Fd()), buf, oob, syscall.MSG_CMSG_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*os.File, 0, n)
+	for i := range messages {
+		fds, err := syscall.ParseUnixRights(&messages[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, fd := range fds {
+			files = append(files, os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd)))
+		}
+	}
+
+	if len(files) != n {
+		for _, f := range files {
+			_ = f.Close()
+		}
+		return nil, fmt.Errorf("expected %d files, got %d", n, len(files))
+	}
+	return files, nil
+}
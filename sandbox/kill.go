@@ -0,0 +1,267 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// name of the child cgroup /Main runs in, below the cgroup of the container in the pids, cpuacct and freezer
+// hierarchies (or the unified one)
+const killGroupName = "run"
+
+// KillGroup is a child cgroup of the container which holds /Main and whatever it forks, so that all of them can be
+// killed or frozen at once, unlike a process group which is left by setsid(2) or setpgid(2).
+// The cgroup of the container keeps the limits, the child has no controllers of its own.
+type KillGroup struct {
+	mu sync.Mutex
+	// the child in the pids or the unified hierarchy, its cgroup.procs is opened anew for every read, see Kill
+	dir *os.File
+	// cgroup.procs of the child in every hierarchy, written to join the child
+	procs []*os.File
+	// cgroup.procs of the container in every hierarchy, written to leave the child
+	parents []*os.File
+	// cgroup.kill of the child, nil unless cgroup v2 on linux 5.14+
+	kill *os.File
+	// the child holding cgroup.freeze (v2) or freezer.state (v1), nil if there is no freezer
+	freezer *os.File
+	// /sys/fs/cgroup cannot be told apart once pivot_root has hidden it
+	v2          bool
+	containerID string
+}
+
+// NewKillGroup creates the child cgroup and keeps its files open, must be called before pivot_root,
+// /sys/fs/cgroup is not reachable afterwards
+//noinspection GoUnusedExportedFunction
+func NewKillGroup(containerID string) (*KillGroup, error) {
+	v2 := IsCGroupV2()
+	// the cpuacct one keeps the CPU time of /Main apart from that of the container's init, see CPUUsage
+	roots := []string{cgPidPathPrefix, cgCPUAcctPathPrefix, cgFreezerPathPrefix}
+	if v2 {
+		roots = []string{cgUnifiedPath}
+	}
+
+	k := &KillGroup{v2: v2, containerID: containerID}
+	for i, root := range roots {
+		dir := filepath.Join(root, containerID)
+		child := filepath.Join(dir, killGroupName)
+		// the freezer hierarchy is not mounted everywhere, the kill group does without it
+		if _, err := os.Stat(root); root == cgFreezerPathPrefix && os.IsNotExist(err) {
+			continue
+		}
+		if err := os.MkdirAll(child, os.ModePerm); err != nil {
+			_ = k.Close()
+			return nil, err
+		}
+
+		procs, err := os.OpenFile(filepath.Join(child, "cgroup.procs"), os.O_WRONLY, 0)
+		if err != nil {
+			_ = k.Close()
+			return nil, err
+		}
+		k.procs = append(k.procs, procs)
+		parent, err := os.OpenFile(filepath.Join(dir, "cgroup.procs"), os.O_WRONLY, 0)
+		if err != nil {
+			_ = k.Close()
+			return nil, err
+		}
+		k.parents = append(k.parents, parent)
+
+		if i > 0 && root != cgFreezerPathPrefix {
+			continue
+		}
+		f, err := os.Open(child)
+		if err != nil {
+			_ = k.Close()
+			return nil, err
+		}
+		if i == 0 {
+			k.dir = f
+		}
+		if v2 || i > 0 {
+			k.freezer = f
+		}
+	}
+
+	if kill, err := os.OpenFile(filepath.Join(k.dir.Name(), "cgroup.kill"), os.O_WRONLY, 0); err == nil {
+		k.kill = kill
+	}
+	return k, nil
+}
+
+// Enter moves the calling process into the child cgroup, processes forked afterwards start in there
+func (k *KillGroup) Enter() error {
+	return k.move(k.procs)
+}
+
+// Leave moves the calling process back to the cgroup of the container, out of reach of Kill
+func (k *KillGroup) Leave() error {
+	return k.move(k.parents)
+}
+
+func (k *KillGroup) move(procs []*os.File) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// 0 stands for the writer itself, whose pid differs between pid namespaces
+	for _, f := range procs {
+		if _, err := f.Write([]byte("0")); err != nil {
+			return fmt.Errorf("writing 0 to %s failed, err: %s", f.Name(), err.Error())
+		}
+	}
+	return nil
+}
+
+// Kill sends SIGKILL to every task in the child cgroup. It does not wait for them to exit.
+// Tasks frozen by the v1 freezer only die once thawed.
+func (k *KillGroup) Kill() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.kill != nil {
+		if _, err := k.kill.Write([]byte("1")); err != nil {
+			return fmt.Errorf("writing 1 to %s failed, err: %s", k.kill.Name(), err.Error())
+		}
+		return nil
+	}
+
+	// tasks may fork while the list is walked, it is read once more until nothing new shows up.
+	// Killed ones remain listed until they are reaped, so an unchanged list means everyone has been killed.
+	var last string
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		content, err := readAt(k.dir, "cgroup.procs")
+		if err != nil {
+			return err
+		}
+		if len(content) == 0 || content == last {
+			return nil
+		}
+		last = content
+
+		// pids are listed as seen from the pid namespace of the reader
+		for _, pid := range strings.Fields(last) {
+			if p, err := strconv.Atoi(pid); err == nil {
+				_ = syscall.Kill(p, syscall.SIGKILL)
+			}
+		}
+	}
+	return fmt.Errorf("tasks in %s survived for %s", k.dir.Name(), time.Second)
+}
+
+// CPUUsage reads the CPU time consumed in the child cgroup, unlike NewCPUUsage it leaves out the container's init,
+// e.g. copying the output of /Main. Must be called before pivot_root like NewKillGroup.
+func (k *KillGroup) CPUUsage() (*CPUUsage, error) {
+	return newCPUUsage(filepath.Join(k.containerID, killGroupName))
+}
+
+// Pids lists the tasks in the child cgroup as seen from the pid namespace of the caller
+func (k *KillGroup) Pids() ([]int, error) {
+	content, err := readAt(k.dir, "cgroup.procs")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, pid := range strings.Fields(content) {
+		if p, err := strconv.Atoi(pid); err == nil {
+			pids = append(pids, p)
+		}
+	}
+	return pids, nil
+}
+
+// Freeze stops every task in the child cgroup and waits until all of them are stopped
+func (k *KillGroup) Freeze() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.freezer == nil {
+		return fmt.Errorf("no freezer for %s", k.dir.Name())
+	}
+
+	// v2 reports in cgroup.events once every task is stopped, v1 keeps freezer.state at FREEZING until then, see
+	// https://www.kernel.org/doc/Documentation/cgroup-v1/freezer-subsystem.txt
+	name, value, stateFile, frozen := "freezer.state", "FROZEN", "freezer.state", "FROZEN"
+	if k.v2 {
+		name, value, stateFile, frozen = "cgroup.freeze", "1", "cgroup.events", "frozen 1"
+	}
+	if err := writeAt(k.freezer, name, value); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		state, err := readAt(k.freezer, stateFile)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(state, frozen) {
+			return nil
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return fmt.Errorf("tasks in %s not frozen within %s", k.freezer.Name(), time.Second)
+}
+
+// Thaw resumes the tasks stopped by Freeze
+func (k *KillGroup) Thaw() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.freezer == nil {
+		return nil
+	}
+	if k.v2 {
+		return writeAt(k.freezer, "cgroup.freeze", "0")
+	}
+	return writeAt(k.freezer, "freezer.state", "THAWED")
+}
+
+func (k *KillGroup) Close() error {
+	files := append([]*os.File{k.dir, k.kill}, append(k.procs, k.parents...)...)
+	// the freezer is dir itself on v2
+	if k.freezer != k.dir {
+		files = append(files, k.freezer)
+	}
+	for _, f := range files {
+		if f != nil {
+			_ = f.Close()
+		}
+	}
+	return nil
+}
+
+// reads a file of the cgroup dir, opened anew every time: v1 caches the pid list of an open cgroup.procs
+// for a second after every read, only a new file gets a fresh one
+func readAt(dir *os.File, name string) (string, error) {
+	fd, err := syscall.Openat(int(dir.Fd()), name, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return "", fmt.Errorf("openat(%s, %s) failed, err: %s", dir.Name(), name, err.Error())
+	}
+	f := os.NewFile(uintptr(fd), filepath.Join(dir.Name(), name))
+	defer func() { _ = f.Close() }()
+	content, err := ioutil.ReadAll(f)
+	return string(content), err
+}
+
+func writeAt(dir *os.File, name, value string) error {
+	fd, err := syscall.Openat(int(dir.Fd()), name, syscall.O_WRONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("openat(%s, %s) failed, err: %s", dir.Name(), name, err.Error())
+	}
+	f := os.NewFile(uintptr(fd), filepath.Join(dir.Name(), name))
+	defer func() { _ = f.Close() }()
+	if _, err := f.Write([]byte(value)); err != nil {
+		return fmt.Errorf("writing %s to %s failed, err: %s", value, f.Name(), err.Error())
+	}
+	return nil
+}
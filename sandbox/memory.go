@@ -0,0 +1,54 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"path/filepath"
+)
+
+// MemoryPeak reads the peak memory usage of the memory cgroup of a container, which includes justiceInit
+type MemoryPeak struct {
+	stat *cgroupStat
+	// written to reset the peak, empty if it cannot be reset
+	resetValue string
+}
+
+//noinspection GoUnusedExportedFunction
+func NewMemoryPeak(containerID string) (*MemoryPeak, error) {
+	if !IsCGroupV2() {
+		stat, err := openResettableCGroupStat(filepath.Join(cgMemoryPathPrefix, containerID, "memory.max_usage_in_bytes"))
+		if err != nil {
+			return nil, err
+		}
+		return &MemoryPeak{stat: stat, resetValue: "0"}, nil
+	}
+
+	// memory.peak can be reset per open file since linux 6.12, it is read-only before
+	path := filepath.Join(cgUnifiedPath, containerID, "memory.peak")
+	if stat, err := openResettableCGroupStat(path); err == nil {
+		return &MemoryPeak{stat: stat, resetValue: "reset"}, nil
+	}
+	stat, err := openCGroupStat(path, "")
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryPeak{stat: stat}, nil
+}
+
+// Reset starts a new peak, e.g. before each test case. It is a no-op if the kernel cannot reset the peak.
+func (m *MemoryPeak) Reset() error {
+	if m.resetValue == "" {
+		return nil
+	}
+	return m.stat.reset(m.resetValue)
+}
+
+// Peak returns the peak memory usage in bytes since the last Reset
+func (m *MemoryPeak) Peak() (int64, error) {
+	return m.stat.read()
+}
+
+func (m *MemoryPeak) Close() error {
+	return m.stat.close()
+}
@@ -0,0 +1,123 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Mounts are the system dirs set up in the root of the container, all of them are off by default
+type Mounts struct {
+	// a minimal /dev holding null, zero, full, random and urandom only
+	Dev bool `json:"dev"`
+	// a procfs of the container's pid namespace, mounted with hidepid=2
+	Proc bool `json:"proc"`
+	// size of a tmpfs /tmp in MB, 0 means no /tmp
+	TmpSize int64 `json:"tmp_size"`
+	// dirs of the host mounted read-only at the same path, e.g. an interpreter along with its libraries.
+	// Only set up in the disposable roots of InitOverlayNamespace
+	Runtime []string `json:"runtime,omitempty"`
+}
+
+var containerDevices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom"}
+
+// links into /proc/self, only of use along with Proc
+var containerDeviceLinks = map[string]string{
+	"/dev/fd":     "/proc/self/fd",
+	"/dev/stdin":  "/proc/self/fd/0",
+	"/dev/stdout": "/proc/self/fd/1",
+	"/dev/stderr": "/proc/self/fd/2",
+}
+
+// sets up the system dirs under root before pivot_root, while the devices of the host are still reachable.
+// procfs must be mounted before the old root goes away too, the kernel refuses a procfs in a user namespace
+// which cannot see a fully visible one.
+func mountSystemDirs(root string, m Mounts) error {
+	if err := mountRuntime(root, m); err != nil {
+		return err
+	}
+
+	if m.Dev {
+		dev := filepath.Join(root, "dev")
+		if err := mountTmpfs(dev, "size=64k,mode=0755", syscall.MS_NOSUID|syscall.MS_NOEXEC); err != nil {
+			return err
+		}
+		for _, device := range containerDevices {
+			if err := bindMount(device, filepath.Join(root, device), false); err != nil {
+				return err
+			}
+		}
+		for link, target := range containerDeviceLinks {
+			if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+				return err
+			}
+		}
+		// nothing may be created in /dev once it is populated
+		if err := syscall.Mount("", dev, "", syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NOEXEC, ""); err != nil {
+			return fmt.Errorf("syscall.Mount(%s, syscall.MS_REMOUNT|syscall.MS_RDONLY) failed, err: %s", dev, err.Error())
+		}
+	}
+
+	if m.Proc {
+		proc := filepath.Join(root, "proc")
+		if err := os.MkdirAll(proc, 0555); err != nil {
+			return err
+		}
+		if err := syscall.Mount("proc", proc, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "hidepid=2"); err != nil {
+			return fmt.Errorf("syscall.Mount(proc, %s) failed, err: %s", proc, err.Error())
+		}
+	}
+
+	return mountTmp(root, m)
+}
+
+// sets up the system dirs under the root of a run by binding those set up under / by mountSystemDirs
+func bindSystemDirs(root string, m Mounts) error {
+	if err := mountRuntime(root, m); err != nil {
+		return err
+	}
+
+	for _, dir := range []struct {
+		path    string
+		enabled bool
+	}{{"/dev", m.Dev}, {"/proc", m.Proc}} {
+		if dir.enabled {
+			if err := bindMount(dir.path, filepath.Join(root, dir.path), false); err != nil {
+				return err
+			}
+		}
+	}
+
+	return mountTmp(root, m)
+}
+
+// recreates the runtime dirs under root the way the toolchain of the compiler is, see mountToolchainDir
+func mountRuntime(root string, m Mounts) error {
+	for _, dir := range m.Runtime {
+		if err := mountToolchainDir(root, dir); err != nil {
+			return fmt.Errorf("mountToolchainDir(%s, %s) failed, err: %s", root, dir, err.Error())
+		}
+	}
+	return nil
+}
+
+func mountTmp(root string, m Mounts) error {
+	if m.TmpSize <= 0 {
+		return nil
+	}
+	return mountTmpfs(filepath.Join(root, "tmp"), fmt.Sprintf("size=%dm,mode=1777", m.TmpSize), syscall.MS_NOSUID|syscall.MS_NODEV)
+}
+
+func mountTmpfs(target, data string, flags uintptr) error {
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", target, "tmpfs", flags, data); err != nil {
+		return fmt.Errorf("syscall.Mount(tmpfs, %s) failed, err: %s", target, err.Error())
+	}
+	return nil
+}
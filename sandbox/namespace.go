@@ -10,9 +10,26 @@ import (
 	"syscall"
 )
 
+// InitNamespace pivots into newRoot, setting up the system dirs of mounts under it first.
+// Runtime dirs are refused, their mount points would be left in newRoot on the host, see InitOverlayNamespace.
 //noinspection GoUnusedExportedFunction
-func InitNamespace(newRoot string) error {
-	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitNamespace(%s) starting...\n", newRoot))
+func InitNamespace(newRoot string, mounts Mounts) error {
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitNamespace(%s, %+v) starting...\n", newRoot, mounts))
+
+	if len(mounts.Runtime) > 0 {
+		err := fmt.Errorf("runtime dirs %v need an overlay root", mounts.Runtime)
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("InitNamespace(%s) failed, err: %s\n", newRoot, err.Error()))
+		return err
+	}
+
+	if err := makeMountsPrivate(); err != nil {
+		return err
+	}
+
+	if err := mountSystemDirs(newRoot, mounts); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("mountSystemDirs(%s, %+v) failed, err: %s\n", newRoot, mounts, err.Error()))
+		return err
+	}
 
 	if err := pivotRoot(newRoot); err != nil {
 		_, _ = os.Stderr.WriteString(fmt.Sprintf("pivotRoot(%s) failed, err: %s\n", newRoot, err.Error()))
@@ -24,7 +41,16 @@ func InitNamespace(newRoot string) error {
 		return err
 	}
 
-	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitNamespace(%s) done\n", newRoot))
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitNamespace(%s, %+v) done\n", newRoot, mounts))
+	return nil
+}
+
+// makes every mount of our mount namespace private, so that nothing mounted below may propagate back to the host
+func makeMountsPrivate() error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("syscall.Mount(\"\", \"/\", \"\", syscall.MS_REC|syscall.MS_PRIVATE, \"\") failed, err: %s\n", err.Error()))
+		return err
+	}
 	return nil
 }
 
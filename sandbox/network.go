@@ -0,0 +1,65 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// struct ifreq of netdevice(7) carrying ifr_flags
+type ifreqFlags struct {
+	name  [syscall.IFNAMSIZ]byte
+	flags uint16
+	_     [22]byte
+}
+
+// EnterNetworkNamespace moves the calling thread into the network namespace of pid.
+// Same as DropPrivileges, it must be called on a locked OS thread which is thrown away afterwards.
+//noinspection GoUnusedExportedFunction
+func EnterNetworkNamespace(pid int) error {
+	// missing from package syscall, the number is taken from the table of seccomp profiles
+	nr, ok := syscallNumbers["setns"]
+	if !ok {
+		return fmt.Errorf("setns(2) is not supported on this architecture")
+	}
+
+	path := filepath.Join("/proc", strconv.Itoa(pid), "ns", "net")
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, _, errno := syscall.RawSyscall(uintptr(nr), f.Fd(), syscall.CLONE_NEWNET, 0); errno != 0 {
+		return fmt.Errorf("setns(%s) failed, err: %s", path, errno.Error())
+	}
+	return nil
+}
+
+// SetLoopbackUp brings lo up in the network namespace of the calling thread, which assigns 127.0.0.1 and ::1 to it.
+// A new network namespace comes with lo down.
+//noinspection GoUnusedExportedFunction
+func SetLoopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = syscall.Close(fd) }()
+
+	var req ifreqFlags
+	copy(req.name[:], "lo")
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return fmt.Errorf("ioctl(lo, SIOCGIFFLAGS) failed, err: %s", errno.Error())
+	}
+	req.flags |= syscall.IFF_UP
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return fmt.Errorf("ioctl(lo, SIOCSIFFLAGS) failed, err: %s", errno.Error())
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// OOMCounter reads the number of OOM kills in the memory cgroup of a container
+type OOMCounter struct {
+	stat *cgroupStat
+	// false if stat is memory.failcnt, see Exact
+	exact bool
+}
+
+//noinspection GoUnusedExportedFunction
+func NewOOMCounter(containerID string) (*OOMCounter, error) {
+	var path, key string
+	exact := true
+	if IsCGroupV2() {
+		path, key = filepath.Join(cgUnifiedPath, containerID, "memory.events"), "oom_kill"
+	} else if oomControl := filepath.Join(cgMemoryPathPrefix, containerID, "memory.oom_control"); hasOOMKill(oomControl) {
+		path, key = oomControl, "oom_kill"
+	} else {
+		// old v1 kernels without `oom_kill`, count how many times the limit was hit instead
+		path, exact = filepath.Join(cgMemoryPathPrefix, containerID, "memory.failcnt"), false
+	}
+
+	stat, err := openCGroupStat(path, key)
+	if err != nil {
+		return nil, err
+	}
+	return &OOMCounter{stat: stat, exact: exact}, nil
+}
+
+// `oom_kill` shows up in memory.oom_control since linux 4.13
+func hasOOMKill(oomControl string) bool {
+	content, err := ioutil.ReadFile(oomControl)
+	return err == nil && bytes.Contains(content, []byte("oom_kill "))
+}
+
+// Count returns the number of OOM kills (or limit hits for memory.failcnt) so far.
+func (c *OOMCounter) Count() (int64, error) {
+	return c.stat.read()
+}
+
+// Exact reports whether Count is the number of OOM kills. memory.failcnt also counts the charges which reclaim
+// got through in the end, a limit hit only means an OOM kill along with a SIGKILL of the task in question.
+func (c *OOMCounter) Exact() bool {
+	return c.exact
+}
+
+func (c *OOMCounter) Close() error {
+	return c.stat.close()
+}
@@ -0,0 +1,122 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+const (
+	overlayLowerDir = "/lower"
+	overlayRunsDir  = "/runs"
+)
+
+// InitOverlayNamespace pivots into a tmpfs at stage, which holds basedir read-only at /lower.
+// Every run then gets a disposable root of its own from NewOverlayRoot, instead of sharing basedir.
+// The system dirs of mounts are set up under stage once and shared by the roots of all runs.
+//noinspection GoUnusedExportedFunction
+func InitOverlayNamespace(stage, basedir string, mounts Mounts) error {
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitOverlayNamespace(%s, %s, %+v) starting...\n", stage, basedir, mounts))
+
+	if err := makeMountsPrivate(); err != nil {
+		return err
+	}
+
+	if err := syscall.Mount("tmpfs", stage, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "size=1m,mode=0755"); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("syscall.Mount(tmpfs, %s) failed, err: %s\n", stage, err.Error()))
+		return err
+	}
+
+	if err := bindMount(basedir, filepath.Join(stage, overlayLowerDir), false); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("bindMount(%s) failed, err: %s\n", basedir, err.Error()))
+		return err
+	}
+
+	if err := mountSystemDirs(stage, Mounts{Dev: mounts.Dev, Proc: mounts.Proc, Runtime: mounts.Runtime}); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("mountSystemDirs(%s, %+v) failed, err: %s\n", stage, mounts, err.Error()))
+		return err
+	}
+
+	runs := filepath.Join(stage, overlayRunsDir)
+	if err := os.MkdirAll(runs, 0700); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("os.MkdirAll(%s, 0700) failed, err: %s\n", runs, err.Error()))
+		return err
+	}
+
+	if err := pivotRoot(stage); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("pivotRoot(%s) failed, err: %s\n", stage, err.Error()))
+		return err
+	}
+
+	if err := syscall.Sethostname([]byte("justice")); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("syscall.Sethostname failed, err: %s\n", err.Error()))
+		return err
+	}
+
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitOverlayNamespace(%s, %s, %+v) done\n", stage, basedir, mounts))
+	return nil
+}
+
+// OverlayRoot is the root of a single run: an overlayfs of /lower with a writable upper layer
+// on a tmpfs, so whatever the run writes is capped in size and discarded by Remove
+type OverlayRoot struct {
+	dir string
+}
+
+var overlayRoots int
+
+// NewOverlayRoot creates a pristine root, must be called after InitOverlayNamespace.
+// size is the limit of the writable layer in MB, mounts should be those given to InitOverlayNamespace.
+//noinspection GoUnusedExportedFunction
+func NewOverlayRoot(size int64, mounts Mounts) (*OverlayRoot, error) {
+	overlayRoots++
+	o := &OverlayRoot{dir: filepath.Join(overlayRunsDir, strconv.Itoa(overlayRoots))}
+
+	if err := os.Mkdir(o.dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := syscall.Mount("tmpfs", o.dir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, fmt.Sprintf("size=%dm,mode=0755", size)); err != nil {
+		_ = os.Remove(o.dir)
+		return nil, err
+	}
+
+	upper, work := filepath.Join(o.dir, "upper"), filepath.Join(o.dir, "work")
+	for _, dir := range []string{upper, work, o.Path()} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			_ = o.Remove()
+			return nil, err
+		}
+	}
+
+	data := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", overlayLowerDir, upper, work)
+	if err := syscall.Mount("overlay", o.Path(), "overlay", syscall.MS_NOSUID|syscall.MS_NODEV, data); err != nil {
+		_ = o.Remove()
+		return nil, err
+	}
+
+	// a fresh /tmp for every run, /dev, /proc and the runtime dirs come from the stage
+	if err := bindSystemDirs(o.Path(), mounts); err != nil {
+		_ = o.Remove()
+		return nil, err
+	}
+	return o, nil
+}
+
+// Path is where the root of the run is mounted
+func (o *OverlayRoot) Path() string {
+	return filepath.Join(o.dir, "merged")
+}
+
+// Remove discards the root along with everything written to it
+func (o *OverlayRoot) Remove() error {
+	_ = syscall.Unmount(o.Path(), syscall.MNT_DETACH)
+	if err := syscall.Unmount(o.dir, syscall.MNT_DETACH); err != nil {
+		return err
+	}
+	return os.Remove(o.dir)
+}
@@ -0,0 +1,51 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// ProcFS is a procfs of the pid namespace of the container kept open by justiceInit, which has no /proc
+// of its own after pivot_root. It is detached right away, so /Main never gets to see it.
+type ProcFS struct {
+	dir *os.File
+}
+
+// OpenProcFS must be called before pivot_root: the kernel refuses a procfs in a user namespace
+// which cannot see a fully visible one
+//noinspection GoUnusedExportedFunction
+func OpenProcFS() (*ProcFS, error) {
+	mountPoint, err := ioutil.TempDir("", "justice-proc-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(mountPoint) }()
+
+	if err := syscall.Mount("proc", mountPoint, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return nil, fmt.Errorf("syscall.Mount(proc, %s) failed, err: %s", mountPoint, err.Error())
+	}
+	fd, err := syscall.Open(mountPoint, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	// the open dir keeps the procfs alive once detached
+	_ = syscall.Unmount(mountPoint, syscall.MNT_DETACH)
+	if err != nil {
+		return nil, err
+	}
+	// named after where /Main would find it, errors are reported with this name
+	return &ProcFS{dir: os.NewFile(uintptr(fd), "/proc")}, nil
+}
+
+// ReadFile reads /proc/<pid>/<name>, pid as seen from the pid namespace of the container
+func (p *ProcFS) ReadFile(pid int, name string) (string, error) {
+	return readAt(p.dir, filepath.Join(strconv.Itoa(pid), name))
+}
+
+func (p *ProcFS) Close() error {
+	return p.dir.Close()
+}
@@ -0,0 +1,313 @@
+// +build linux
+// +build go1.15
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/pkg/reexec"
+	"github.com/justice-oj/sandbox/model"
+	"github.com/justice-oj/sandbox/sandbox"
+	"github.com/satori/go.uuid"
+)
+
+// CompileSpec describes a compile job, see the flags of clike_compiler
+type CompileSpec struct {
+	// dir holding the source file, the compiler runs in it and leaves the artifact there
+	Basedir string
+	// compile command run in Basedir, e.g. gcc Main.c -o Main, nothing is compiled if empty, e.g. for an interpreter
+	Command []string
+	// in ms
+	Timeout int
+	// cgroup limitations of the compiler and its children
+	Limits sandbox.Limits
+	// dirs mounted read-only for the compiler, sandbox.DefaultToolchain if empty
+	Toolchain []string
+	// size of /tmp of the compiler in MB
+	TmpSize int64
+	// diagnostics of the compiler kept in the result in bytes
+	DiagnosticsLimit int
+	// file expected in Basedir after a clean compile if given, some compilers exit with 0 without producing anything,
+	// e.g. javac given a class of another name
+	Artifact string
+}
+
+// DefaultCompileSpec returns the defaults of clike_compiler for a source file in basedir, short of the compile command:
+// 5s, 512MB, a full CPU, 64 tasks, a /tmp of 64MB and 16KB of diagnostics
+//noinspection GoUnusedExportedFunction
+func DefaultCompileSpec(basedir string) CompileSpec {
+	spec := CompileSpec{
+		Basedir:          basedir,
+		Timeout:          5000,
+		Limits:           sandbox.DefaultLimits(512),
+		TmpSize:          64,
+		DiagnosticsLimit: 16 * 1024,
+	}
+	spec.Limits.CPUQuota = 100000
+	return spec
+}
+
+func (spec CompileSpec) validate() error {
+	switch {
+	case spec.Timeout <= 0:
+		return fmt.Errorf("timeout must be positive, got %d", spec.Timeout)
+	case spec.TmpSize <= 0:
+		// size=0 would leave the tmpfs unlimited
+		return fmt.Errorf("size of /tmp must be positive, got %d", spec.TmpSize)
+	case spec.DiagnosticsLimit < 0:
+		return fmt.Errorf("diagnostics limit must not be negative, got %d", spec.DiagnosticsLimit)
+	}
+	return spec.Limits.Validate()
+}
+
+// Compile runs the compile command of spec inside new namespaces and cgroups.
+// A compiler which fails, times out or runs out of memory is reported by the model.CompileResult,
+// an error means spec is invalid, see DefaultCompileSpec, the sandbox failed or ctx was done first.
+//noinspection GoUnusedExportedFunction
+func Compile(ctx context.Context, spec CompileSpec) (*model.CompileResult, error) {
+	workdir, err := filepath.Abs(spec.Basedir)
+	if err != nil {
+		return nil, err
+	}
+	if len(spec.Command) > 0 {
+		if err := spec.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	r := new(model.CompileResult).GetCompileOKResult()
+	if len(spec.Command) > 0 {
+		if r, err = compile(ctx, workdir, spec); err != nil {
+			return nil, err
+		}
+	}
+
+	if spec.Artifact != "" && r.Status == model.CompileStatusOk {
+		if _, err := os.Stat(filepath.Join(workdir, spec.Artifact)); err != nil {
+			r.GetCompileErrorResult(r.Diagnostics + fmt.Sprintf("artifact %s not found\n", spec.Artifact))
+		}
+	}
+	return r, nil
+}
+
+// runs the compile command in workdir inside new namespaces and cgroups
+func compile(ctx context.Context, workdir string, spec CompileSpec) (*model.CompileResult, error) {
+	r := new(model.CompileResult)
+	job := compileJob{
+		Basedir:          workdir,
+		Toolchain:        spec.Toolchain,
+		TmpSize:          spec.TmpSize,
+		Limits:           spec.Limits,
+		DiagnosticsLimit: spec.DiagnosticsLimit,
+		Command:          spec.Command,
+	}
+	if len(job.Toolchain) == 0 {
+		job.Toolchain = sandbox.DefaultToolchain
+	}
+
+	// mount point of the root of the compiler, stays empty on the host
+	newRoot, err := ioutil.TempDir("", "justice-compiler-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(newRoot) }()
+	job.NewRoot = newRoot
+
+	// justiceCompile joins the cgroups itself, whatever is left in them is killed once it is gone
+	job.ContainerID = uuid.NewV4().String()
+	defer func() { _ = sandbox.RemoveCGroup(job.ContainerID) }()
+
+	encodedJob, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	cmd := reexec.Command("justiceCompile")
+	cmd.Stdin = bytes.NewReader(encodedJob)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = namespaceAttr()
+
+	if err := cmd.Start(); err != nil {
+		return nil, &Error{Op: "justiceCompile", Err: err}
+	}
+	stopWatching := killOnDone(ctx, cmd)
+
+	// justiceCompile is the init process of the new pid namespace, the compiler dies along with it
+	var timedOut int32
+	timer := time.AfterFunc(time.Duration(spec.Timeout)*time.Millisecond, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		_ = cmd.Process.Kill()
+	})
+	defer timer.Stop()
+
+	startTime := time.Now()
+	err = cmd.Wait()
+	runtime := time.Since(startTime).Milliseconds()
+	stopWatching()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if atomic.LoadInt32(&timedOut) == 1 {
+		r.Runtime = runtime
+		return r.GetCompileTimeoutResult(), nil
+	}
+	if err != nil {
+		return nil, &Error{Op: "justiceCompile", Err: err}
+	}
+	if err := json.Unmarshal(stdout.Bytes(), r); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("json.Unmarshal(%s) failed, err: %s\n", stdout.String(), err.Error()))
+		return nil, &Error{Op: "justiceCompile", Err: err}
+	}
+	r.Runtime = runtime
+	return r, nil
+}
+
+// compileJob is handed over by compile() to justiceCompile as JSON on its stdin
+type compileJob struct {
+	// mount point of the root of the compiler
+	NewRoot     string         `json:"new_root"`
+	Basedir     string         `json:"basedir"`
+	Toolchain   []string       `json:"toolchain"`
+	TmpSize     int64          `json:"tmp_size"`
+	ContainerID string         `json:"container_id"`
+	Limits      sandbox.Limits `json:"limits"`
+	// in bytes
+	DiagnosticsLimit int      `json:"diagnostics_limit"`
+	Command          []string `json:"command"`
+}
+
+// runs the compiler inside the namespaces and writes a model.CompileResult to os.Stdout,
+// exiting without writing anything tells Compile() that the sandbox is broken
+func justiceCompile() {
+	var job compileJob
+	if err := json.NewDecoder(os.Stdin).Decode(&job); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("decoding the compile job failed, err: %s\n", err.Error()))
+		os.Exit(1)
+	}
+	if len(job.Command) == 0 {
+		_, _ = os.Stderr.WriteString("the compile job holds no command\n")
+		os.Exit(1)
+	}
+
+	// the pid is resolved in our pid namespace, where we are init
+	if err := sandbox.InitCGroup("1", job.ContainerID, job.Limits); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.InitCGroup(1, %s) failed, err: %s\n", job.ContainerID, err.Error()))
+		os.Exit(1)
+	}
+
+	// opened before pivot_root, /sys/fs/cgroup is not reachable afterwards
+	oom, err := sandbox.NewOOMCounter(job.ContainerID)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.NewOOMCounter(%s) failed, err: %s\n", job.ContainerID, err.Error()))
+		os.Exit(1)
+	}
+	defer func() { _ = oom.Close() }()
+
+	if err := sandbox.InitCompilerNamespace(job.NewRoot, job.Basedir, job.Toolchain, job.TmpSize); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.InitCompilerNamespace(%s, %s) failed, err: %s\n", job.NewRoot, job.Basedir, err.Error()))
+		os.Exit(1)
+	}
+
+	diagnostics := &truncatedBuffer{limit: job.DiagnosticsLimit}
+	cmd := exec.Command(job.Command[0], job.Command[1:]...)
+	cmd.Stdout = ioutil.Discard
+	cmd.Stderr = diagnostics
+	cmd.Dir = "/work"
+	cmd.Env = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin", "HOME=/tmp", "TMPDIR=/tmp"}
+
+	err = cmd.Run()
+	r := &model.CompileResult{Diagnostics: diagnostics.String(), ExitCode: -1}
+	if cmd.ProcessState != nil {
+		r.ExitCode = cmd.ProcessState.ExitCode()
+		// includes cc1, as and ld, which are waited for by the driver
+		r.Memory = cmd.ProcessState.SysUsage().(*syscall.Rusage).Maxrss / 1024
+	}
+
+	switch {
+	case err == nil:
+		r.GetCompileOKResult()
+	// the driver survives when the OOM killer picks cc1 or ld, only told apart from a compile error by oom_kill
+	case oomKilled(oom, 0, cmd.ProcessState):
+		r.GetCompilerMemoryExceededResult()
+	default:
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("err: %s\n", err.Error()))
+		r.GetCompileErrorResult(r.Diagnostics)
+	}
+	_ = json.NewEncoder(os.Stdout).Encode(r)
+}
+
+// truncatedBuffer keeps the first limit bytes written to it and notes that the rest was cut off
+type truncatedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *truncatedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.truncated = true
+		b.buf.Write(p[:room])
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *truncatedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n... (truncated)\n"
+	}
+	return b.buf.String()
+}
+
+// new namespaces of justiceCompile and justiceInit, uid and gid 0 inside are those of the caller
+func namespaceAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWNS |
+			syscall.CLONE_NEWUTS |
+			syscall.CLONE_NEWIPC |
+			syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWNET |
+			syscall.CLONE_NEWUSER,
+		UidMappings: []syscall.SysProcIDMap{
+			{
+				ContainerID: 0,
+				HostID:      os.Getuid(),
+				Size:        1,
+			},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{
+				ContainerID: 0,
+				HostID:      os.Getgid(),
+				Size:        1,
+			},
+		},
+	}
+}
+
+// kills cmd, the init of its pid namespace, once ctx is done, until the returned func is called
+func killOnDone(ctx context.Context, cmd *exec.Cmd) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = cmd.Process.Kill()
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
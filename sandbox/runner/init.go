@@ -0,0 +1,763 @@
+// +build linux
+// +build go1.15
+
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/justice-oj/sandbox/model"
+	"github.com/justice-oj/sandbox/sandbox"
+)
+
+// initJob is handed over by Run() to justiceInit as the first JSON value on its stdin, followed by the test cases
+type initJob struct {
+	// root of the container
+	Basedir     string `json:"basedir"`
+	ContainerID string `json:"container_id"`
+	// /Main unless given by a language profile
+	Command []string `json:"command"`
+	// in ms
+	Timeout    int64 `json:"timeout"`
+	CPUTimeout int64 `json:"cpu_timeout"`
+	// in KB
+	OutputLimit int64          `json:"output_limit"`
+	Limits      sandbox.Limits `json:"limits"`
+	// Run() passes the pipes to and from the interactor over the control socket
+	Interactive bool   `json:"interactive"`
+	Seccomp     string `json:"seccomp"`
+	// empty unless every run gets an overlay root of its own
+	OverlayStage string         `json:"overlay_stage"`
+	OverlaySize  int64          `json:"overlay_size"`
+	Mounts       sandbox.Mounts `json:"mounts"`
+	// in-namespace ids /Main runs as, 0 keeps uid 0 without any capability
+	UID   int  `json:"uid"`
+	GID   int  `json:"gid"`
+	Debug bool `json:"debug"`
+}
+
+// runs the command against the test cases Run() sends one by one inside the namespaces, answering each with a model.Result
+func justiceInit() {
+	// Run() passes the pipes to and from the interactor in interactive mode, or the input file otherwise, over this socket
+	control := os.NewFile(3, "control")
+	syscall.CloseOnExec(3)
+
+	// test cases arrive one by one on os.Stdin after the job, each one is answered on os.Stdout
+	// before Run() decides whether to send the next one
+	decoder, encoder := json.NewDecoder(os.Stdin), json.NewEncoder(os.Stdout)
+	var job initJob
+	if err := decoder.Decode(&job); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("decoding the init job failed, err: %s\n", err.Error()))
+		os.Exit(0)
+	}
+	if len(job.Command) == 0 {
+		_, _ = os.Stderr.WriteString("the init job holds no command\n")
+		os.Exit(0)
+	}
+	containerID, cpuTimeout, interactive := job.ContainerID, job.CPUTimeout, job.Interactive
+
+	rn := &runner{
+		command:     job.Command,
+		timeout:     job.Timeout,
+		cpuTimeout:  job.CPUTimeout,
+		outputLimit: job.OutputLimit * 1024,
+		overlay:     job.OverlayStage != "",
+		overlaySize: job.OverlaySize,
+		mounts:      job.Mounts,
+		uid:         job.UID,
+		gid:         job.GID,
+		debug:       job.Debug,
+	}
+
+	// the pid is resolved in our pid namespace, where we are init, /Main inherits the cgroups on fork.
+	// Exiting without writing any result tells Run() that the sandbox is broken
+	if err := sandbox.InitCGroup("1", containerID, job.Limits); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.InitCGroup(1, %s) failed, err: %s\n", containerID, err.Error()))
+		os.Exit(0)
+	}
+
+	// opened before pivot_root, /sys/fs/cgroup is not reachable afterwards
+	var err error
+	if rn.oom, err = sandbox.NewOOMCounter(containerID); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.NewOOMCounter(%s) failed, MLE detection disabled, err: %s\n", containerID, err.Error()))
+	} else {
+		defer func() { _ = rn.oom.Close() }()
+	}
+
+	if rn.memory, err = sandbox.NewMemoryPeak(containerID); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.NewMemoryPeak(%s) failed, cgroup max memory not reported, err: %s\n", containerID, err.Error()))
+	} else {
+		defer func() { _ = rn.memory.Close() }()
+	}
+
+	if rn.group, err = sandbox.NewKillGroup(containerID); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.NewKillGroup(%s) failed, only the process group is killed, err: %s\n", containerID, err.Error()))
+	} else {
+		// rn.group is dropped if leaving it ever fails
+		defer func(group *sandbox.KillGroup) { _ = group.Close() }(rn.group)
+	}
+
+	// /Main is charged for its own CPU time only, not for justiceInit copying its output
+	if rn.group != nil {
+		if rn.cpu, err = rn.group.CPUUsage(); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("rn.group.CPUUsage() failed, err: %s\n", err.Error()))
+		}
+	}
+	if rn.cpu == nil {
+		if rn.cpu, err = sandbox.NewCPUUsage(containerID); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.NewCPUUsage(%s) failed, err: %s\n", containerID, err.Error()))
+			if cpuTimeout > 0 {
+				os.Exit(0)
+			}
+		}
+	}
+	if rn.cpu != nil {
+		defer func() { _ = rn.cpu.Close() }()
+	}
+
+	// /Main is inspected through it in debug mode
+	if job.Debug {
+		if rn.proc, err = sandbox.OpenProcFS(); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.OpenProcFS() failed, tasks are not inspected, err: %s\n", err.Error()))
+		} else {
+			defer func() { _ = rn.proc.Close() }()
+		}
+	}
+
+	if job.Seccomp != "" {
+		if rn.filter, err = sandbox.LoadSeccompProfile(job.Seccomp); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.LoadSeccompProfile(%s) failed, err: %s\n", job.Seccomp, err.Error()))
+			os.Exit(0)
+		}
+	}
+
+	if rn.overlay {
+		if err := sandbox.InitOverlayNamespace(job.OverlayStage, job.Basedir, job.Mounts); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.InitOverlayNamespace(%s, %s) failed, err: %s\n", job.OverlayStage, job.Basedir, err.Error()))
+			os.Exit(0)
+		}
+	} else if err := sandbox.InitNamespace(job.Basedir, job.Mounts); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.InitNamespace(%s) failed, err: %s\n", job.Basedir, err.Error()))
+		os.Exit(0)
+	}
+
+	for i := 0; ; i++ {
+		var c model.TestCase
+		if err := decoder.Decode(&c); err != nil {
+			if err != io.EOF {
+				_, _ = os.Stderr.WriteString(fmt.Sprintf("decoder.Decode() failed, err: %s\n", err.Error()))
+			}
+			return
+		}
+
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("running test case #%d\n", i))
+		var r *model.Result
+		if interactive {
+			files, err := sandbox.ReceiveFiles(control, 2)
+			if err != nil {
+				_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.ReceiveFiles() failed, err: %s\n", err.Error()))
+				return
+			}
+			r = rn.runTestCase(files[0], files[1])
+			// the interactor sees EOF only after every copy of the pipe is closed
+			_, _ = files[0].Close(), files[1].Close()
+		} else if c.InputFile != "" {
+			// the input file is opened by Run() and becomes stdin of /Main as is, nothing is buffered here
+			files, err := sandbox.ReceiveFiles(control, 1)
+			if err != nil {
+				_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.ReceiveFiles() failed, err: %s\n", err.Error()))
+				return
+			}
+			r = rn.runTestCase(files[0], nil)
+			_ = files[0].Close()
+		} else {
+			r = rn.runTestCase(strings.NewReader(c.Input), nil)
+		}
+
+		if err := encoder.Encode(r); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("encoder.Encode() failed, err: %s\n", err.Error()))
+			return
+		}
+	}
+}
+
+// runner holds the limits and cgroup handles shared by every test case run by justiceInit
+type runner struct {
+	command []string
+	// wall time limit in ms
+	timeout int64
+	// CPU time limit in ms, 0 means unlimited
+	cpuTimeout int64
+	// in bytes
+	outputLimit int64
+	oom         *sandbox.OOMCounter
+	cpu         *sandbox.CPUUsage
+	memory      *sandbox.MemoryPeak
+	group       *sandbox.KillGroup
+	// run in a disposable overlay root of overlaySize MB instead of basedir
+	overlay     bool
+	overlaySize int64
+	// system dirs in the root of every run
+	mounts   sandbox.Mounts
+	filter   *sandbox.SeccompFilter
+	uid, gid int
+	// freeze and inspect /Main before it is killed for hitting a limit
+	debug bool
+	proc  *sandbox.ProcFS
+	// guards diagnostic, which is recorded by the watchdogs of the current run
+	mu         sync.Mutex
+	diagnostic *model.Diagnostic
+}
+
+// runs the command, /Main by default, against a single test case, must be called after sandbox.InitNamespace.
+// stdout of /Main is captured unless given, e.g. the pipe to an interactor.
+// A clean run is reported as accepted with the raw output attached, Run() makes the final verdict.
+func (rn *runner) runTestCase(stdin io.Reader, stdout io.Writer) *model.Result {
+	r := new(model.Result)
+
+	var root string
+	if rn.overlay {
+		o, err := sandbox.NewOverlayRoot(rn.overlaySize, rn.mounts)
+		if err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.NewOverlayRoot(%d) failed, err: %s\n", rn.overlaySize, err.Error()))
+			r.ExitCode, r.Reason = -1, "failed to start"
+			return r.GetRuntimeErrorTaskResult()
+		}
+		defer func() { _ = o.Remove() }()
+		root = o.Path()
+	}
+
+	cmd := exec.Command(rn.command[0], rn.command[1:]...)
+	// stdout and stderr share the same budget, /Main is killed once it is used up
+	ol := &outputLimiter{limit: rn.outputLimit, onExceed: func() {
+		rn.killOnLimit(cmd, "output limit")
+	}}
+	o, e := &limitedBuffer{limiter: ol}, &limitedBuffer{limiter: ol}
+	cmd.Stdin = stdin
+	cmd.Stdout = o
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = e
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+	cmd.Env = []string{"PS1=[justice] # "}
+
+	oomKillsBefore, cpuBefore := oomKills(rn.oom), cpuUsage(rn.cpu)
+	rn.resetMemoryPeak()
+	startTime := time.Now().UnixNano() / 1e6
+	rn.enterKillGroup()
+	err := startIsolated(cmd, rn.filter, root, rn.uid, rn.gid)
+	rn.leaveKillGroup()
+
+	// set to 1 once the watchdog below has killed /Main
+	var timedOut int32
+	var w *cpuWatcher
+	if err == nil {
+		// armed once cmd.Process is there to kill, the time taken to start /Main counts against the limit
+		elapsed := time.Now().UnixNano()/1e6 - startTime
+		timer := time.AfterFunc(time.Duration(rn.timeout-elapsed)*time.Millisecond, func() {
+			atomic.StoreInt32(&timedOut, 1)
+			rn.killOnLimit(cmd, "timeout")
+		})
+		defer timer.Stop()
+
+		w = rn.watchCPU(cmd, cpuBefore)
+		err = cmd.Wait()
+		w.stop()
+		// nothing forked by /Main outlives the verdict, detached or not
+		rn.kill(cmd)
+		reapOrphans()
+	}
+	endTime := time.Now().UnixNano() / 1e6
+	r.WallTime = endTime - startTime
+	rn.reportUsage(r, cmd.ProcessState)
+	r.Diagnostic = rn.takeDiagnostic()
+
+	if w != nil && w.fired() {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("err: %v, CPU time limit exceeded\n", err))
+		return r.GetTimeLimitExceededErrorTaskResult((cpuUsage(rn.cpu) - cpuBefore).Milliseconds())
+	}
+
+	// the interactor hung up on /Main, which is up to the interactor to judge
+	if err != nil && stdout != nil && killedBy(cmd.ProcessState, syscall.SIGPIPE) {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("err: %s, left to the interactor\n", err.Error()))
+		err = nil
+	}
+
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("err: %s\n", err.Error()))
+		if oomKilled(rn.oom, oomKillsBefore, cmd.ProcessState) {
+			return r.GetMemoryLimitExceededErrorTaskResult()
+		}
+		if ol.exceeded() {
+			return r.GetOutputLimitExceededErrorTaskResult()
+		}
+		if rn.filter != nil && killedBy(cmd.ProcessState, syscall.SIGSYS) {
+			return r.GetRestrictedFunctionErrorTaskResult()
+		}
+		// only a SIGKILL sent by our watchdog counts as TLE, /Main may crash by itself while its children linger
+		if atomic.LoadInt32(&timedOut) == 1 && killedBy(cmd.ProcessState, syscall.SIGKILL) {
+			return r.GetTimeLimitExceededErrorTaskResult(cpuTime(cmd.ProcessState))
+		}
+		r.Reason = runtimeErrorReason(cmd.ProcessState)
+		return r.GetRuntimeErrorTaskResult()
+	}
+
+	if ol.exceeded() {
+		return r.GetOutputLimitExceededErrorTaskResult()
+	}
+
+	if e.Len() > 0 {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("stderr: %s\n", e.String()))
+		r.Reason = "output on stderr"
+		return r.GetRuntimeErrorTaskResult()
+	}
+
+	// timeCost value 0 will be omitted
+	timeCost := r.Runtime
+	if timeCost == 0 {
+		timeCost = 1
+	}
+	r.Output = o.String()
+	return r.GetAcceptedTaskResult(timeCost, r.Memory)
+}
+
+// fills in the resource usage of /Main whatever the verdict is, Runtime and Memory included
+func (rn *runner) reportUsage(r *model.Result, state *os.ProcessState) {
+	r.ExitCode = -1
+	if state == nil {
+		return
+	}
+
+	r.ExitCode = state.ExitCode()
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		r.Signal = signalName(status.Signal())
+	}
+
+	rusage := state.SysUsage().(*syscall.Rusage)
+	// ms, MB
+	r.Runtime, r.Memory = cpuTime(state), rusage.Maxrss/1024
+	r.Usage = &model.Usage{
+		UserTime:                   state.UserTime().Milliseconds(),
+		SysTime:                    state.SystemTime().Milliseconds(),
+		MaxRSS:                     rusage.Maxrss,
+		CGroupMaxMemory:            rn.memoryPeak() / 1024,
+		VoluntaryContextSwitches:   rusage.Nvcsw,
+		InvoluntaryContextSwitches: rusage.Nivcsw,
+		MinorPageFaults:            rusage.Minflt,
+		MajorPageFaults:            rusage.Majflt,
+	}
+}
+
+func (rn *runner) resetMemoryPeak() {
+	if rn.memory == nil {
+		return
+	}
+	if err := rn.memory.Reset(); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("memory.Reset() failed, err: %s\n", err.Error()))
+	}
+}
+
+// peak memory usage of the container's cgroup in bytes, 0 if unknown
+func (rn *runner) memoryPeak() int64 {
+	if rn.memory == nil {
+		return 0
+	}
+	peak, err := rn.memory.Peak()
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("memory.Peak() failed, err: %s\n", err.Error()))
+		return 0
+	}
+	return peak
+}
+
+// signals /Main may die of, with the reason shown to users
+var signals = map[syscall.Signal]struct{ name, reason string }{
+	syscall.SIGHUP:  {"SIGHUP", "hangup"},
+	syscall.SIGINT:  {"SIGINT", "interrupted"},
+	syscall.SIGQUIT: {"SIGQUIT", "quit"},
+	syscall.SIGILL:  {"SIGILL", "illegal instruction"},
+	syscall.SIGTRAP: {"SIGTRAP", "trace trap"},
+	syscall.SIGABRT: {"SIGABRT", "aborted, e.g. failed assertion or uncaught exception"},
+	syscall.SIGBUS:  {"SIGBUS", "bus error, e.g. misaligned memory access"},
+	syscall.SIGFPE:  {"SIGFPE", "division by zero"},
+	syscall.SIGKILL: {"SIGKILL", "killed"},
+	syscall.SIGUSR1: {"SIGUSR1", "user defined signal 1"},
+	syscall.SIGSEGV: {"SIGSEGV", "segmentation fault, e.g. invalid memory access or stack overflow"},
+	syscall.SIGUSR2: {"SIGUSR2", "user defined signal 2"},
+	syscall.SIGPIPE: {"SIGPIPE", "broken pipe"},
+	syscall.SIGALRM: {"SIGALRM", "alarm clock"},
+	syscall.SIGTERM: {"SIGTERM", "terminated"},
+	syscall.SIGXCPU: {"SIGXCPU", "CPU time limit exceeded"},
+	syscall.SIGXFSZ: {"SIGXFSZ", "file size limit exceeded"},
+	syscall.SIGSYS:  {"SIGSYS", "bad system call"},
+}
+
+func signalName(sig syscall.Signal) string {
+	if s, ok := signals[sig]; ok {
+		return s.name
+	}
+	return fmt.Sprintf("signal %d", int(sig))
+}
+
+// classifies a runtime error of /Main, the frontend shows it as e.g. "Runtime Error (SIGFPE - division by zero)"
+func runtimeErrorReason(state *os.ProcessState) string {
+	if state == nil {
+		return "failed to start"
+	}
+
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if ok && status.Signaled() {
+		if s, ok := signals[status.Signal()]; ok {
+			return s.reason
+		}
+		return "killed by " + signalName(status.Signal())
+	}
+	return "non-zero exit code"
+}
+
+// user + sys time of the process, its threads and the children it has waited for, in ms
+func cpuTime(state *os.ProcessState) int64 {
+	return (state.UserTime() + state.SystemTime()).Milliseconds()
+}
+
+// cpuWatcher polls the CPU usage of the kill group, which also covers children
+// that have not been waited for, and kills the process group once the limit is used up
+type cpuWatcher struct {
+	done chan struct{}
+	// set to 1 once /Main has been killed
+	killed int32
+}
+
+func (rn *runner) watchCPU(cmd *exec.Cmd, before time.Duration) *cpuWatcher {
+	w := &cpuWatcher{done: make(chan struct{})}
+	if rn.cpuTimeout <= 0 || rn.cpu == nil {
+		return w
+	}
+
+	limit := time.Duration(rn.cpuTimeout) * time.Millisecond
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+				if cpuUsage(rn.cpu)-before > limit {
+					atomic.StoreInt32(&w.killed, 1)
+					rn.killOnLimit(cmd, "cpu timeout")
+					return
+				}
+			}
+		}
+	}()
+	return w
+}
+
+func (w *cpuWatcher) stop() {
+	close(w.done)
+}
+
+func (w *cpuWatcher) fired() bool {
+	return atomic.LoadInt32(&w.killed) == 1
+}
+
+// kills /Main once it has hit limit, in debug mode every task it left is frozen and inspected first
+func (rn *runner) killOnLimit(cmd *exec.Cmd, limit string) {
+	if !rn.debug {
+		rn.kill(cmd)
+		return
+	}
+
+	rn.recordDiagnostic(limit)
+	rn.kill(cmd)
+	// frozen tasks only die once thawed on v1
+	if rn.group != nil {
+		if err := rn.group.Thaw(); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("rn.group.Thaw() failed, err: %s\n", err.Error()))
+		}
+	}
+}
+
+// records the diagnostic of the first limit hit by the current run, leaving the tasks frozen
+func (rn *runner) recordDiagnostic(limit string) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	if rn.diagnostic != nil {
+		return
+	}
+
+	d := &model.Diagnostic{Limit: limit}
+	rn.diagnostic = d
+	if rn.group == nil {
+		d.Error = "no kill group to freeze"
+		return
+	}
+	if err := rn.group.Freeze(); err != nil {
+		d.Error = err.Error()
+		return
+	}
+	pids, err := rn.group.Pids()
+	if err != nil {
+		d.Error = err.Error()
+		return
+	}
+	for _, pid := range pids {
+		d.Tasks = append(d.Tasks, rn.inspect(pid))
+	}
+}
+
+func (rn *runner) inspect(pid int) model.TaskSnapshot {
+	t := model.TaskSnapshot{Pid: pid}
+	if rn.proc == nil {
+		t.Errors = append(t.Errors, "no procfs to inspect tasks through")
+		return t
+	}
+
+	var err error
+	for name, content := range map[string]*string{"status": &t.Status, "stack": &t.Stack, "maps": &t.Maps} {
+		if *content, err = rn.proc.ReadFile(pid, name); err != nil {
+			t.Errors = append(t.Errors, err.Error())
+		}
+	}
+	sort.Strings(t.Errors)
+	return t
+}
+
+// hands the diagnostic of the current run over, leaving none for the next one
+func (rn *runner) takeDiagnostic() *model.Diagnostic {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	d := rn.diagnostic
+	rn.diagnostic = nil
+	return d
+}
+
+// kills /Main along with everything it forked, also those which left its process group if the kill group is in place
+func (rn *runner) kill(cmd *exec.Cmd) {
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	if rn.group == nil {
+		return
+	}
+	if err := rn.group.Kill(); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("rn.group.Kill() failed, err: %s\n", err.Error()))
+	}
+}
+
+// justiceInit is the init of its pid namespace, whatever /Main left behind ends up as its children once killed,
+// lingering as zombies unless reaped. Must not be called while /Main itself is still to be waited for.
+func reapOrphans() {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+		if err != nil {
+			// ECHILD, nothing left
+			return
+		}
+		if pid == 0 {
+			// killed but not dead yet
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// /Main is forked inside the kill group, justiceInit itself stays out of it
+func (rn *runner) enterKillGroup() {
+	if rn.group == nil {
+		return
+	}
+	if err := rn.group.Enter(); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("rn.group.Enter() failed, err: %s\n", err.Error()))
+	}
+}
+
+func (rn *runner) leaveKillGroup() {
+	if rn.group == nil {
+		return
+	}
+	if err := rn.group.Leave(); err != nil {
+		// Kill would take justiceInit down along with /Main
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("rn.group.Leave() failed, err: %s\n", err.Error()))
+		rn.group = nil
+	}
+}
+
+// number of idle OS threads reserveThreads leaves to justiceInit before /Main starts
+const spareThreads = 8
+
+// the thread which starts /Main is thrown away and the Go runtime replaces it on demand, which fails
+// once /Main has used up pids.max, e.g. a fork bomb. n goroutines locked at the same time make the runtime
+// create n threads up front, which stay idle once unlocked.
+func reserveThreads(n int) {
+	var ready, released sync.WaitGroup
+	release := make(chan struct{})
+	ready.Add(n)
+	released.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			runtime.LockOSThread()
+			ready.Done()
+			<-release
+			runtime.UnlockOSThread()
+			released.Done()
+		}()
+	}
+	ready.Wait()
+	close(release)
+	// a thread is only up for grabs once unlocked, which must happen before /Main is started
+	released.Wait()
+}
+
+// starts cmd on a locked OS thread chrooted into root if given, running as uid and gid without any capability
+// and carrying the seccomp filter if given, so that only /Main inherits them
+func startIsolated(cmd *exec.Cmd, filter *sandbox.SeccompFilter, root string, uid, gid int) error {
+	reserveThreads(spareThreads)
+
+	errCh := make(chan error, 1)
+	go func() {
+		// never unlocked: the thread is terminated along with this goroutine and takes the filter with it
+		runtime.LockOSThread()
+		if root != "" {
+			// a root of its own for this thread, chroot in the child would have to pass the filter
+			if err := syscall.Unshare(syscall.CLONE_FS); err != nil {
+				errCh <- err
+				return
+			}
+			if err := syscall.Chroot(root); err != nil {
+				errCh <- err
+				return
+			}
+			if err := syscall.Chdir("/"); err != nil {
+				errCh <- err
+				return
+			}
+		}
+		// before the filter, which may not allow the syscalls involved
+		if err := sandbox.DropPrivileges(uid, gid); err != nil {
+			errCh <- err
+			return
+		}
+		if filter != nil {
+			if err := filter.Install(); err != nil {
+				errCh <- err
+				return
+			}
+		}
+		errCh <- cmd.Start()
+	}()
+	return <-errCh
+}
+
+// outputLimiter counts bytes written by /Main across stdout and stderr
+type outputLimiter struct {
+	mu       sync.Mutex
+	limit    int64
+	written  int64
+	onExceed func()
+}
+
+// reserves n bytes, returns false once the limit is exceeded
+func (l *outputLimiter) reserve(n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.written > l.limit {
+		return false
+	}
+	l.written += int64(n)
+	if l.written > l.limit {
+		l.onExceed()
+		return false
+	}
+	return true
+}
+
+func (l *outputLimiter) exceeded() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.written > l.limit
+}
+
+// limitedBuffer silently drops writes beyond the shared limit, returning an error here
+// would only make exec stop draining the pipe.
+// bytes.Buffer is not embedded on purpose: its ReadFrom would let io.Copy bypass Write.
+type limitedBuffer struct {
+	buf     bytes.Buffer
+	limiter *outputLimiter
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if !b.limiter.reserve(len(p)) {
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Len() int {
+	return b.buf.Len()
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+// number of OOM kills in the container's memory cgroup so far, 0 if unknown
+func oomKills(oom *sandbox.OOMCounter) int64 {
+	if oom == nil {
+		return 0
+	}
+	count, err := oom.Count()
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("oom.Count() failed, err: %s\n", err.Error()))
+		return 0
+	}
+	return count
+}
+
+// reports whether the OOM killer has struck in the container's memory cgroup since before, which the process
+// has to have been killed by unless the count is exact
+func oomKilled(oom *sandbox.OOMCounter, before int64, state *os.ProcessState) bool {
+	if oomKills(oom) <= before {
+		return false
+	}
+	return oom.Exact() || killedBy(state, syscall.SIGKILL)
+}
+
+// CPU time consumed in the kill group (or the container's cgroup without one) so far, 0 if unknown
+func cpuUsage(cpu *sandbox.CPUUsage) time.Duration {
+	if cpu == nil {
+		return 0
+	}
+	usage, err := cpu.Usage()
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("cpu.Usage() failed, err: %s\n", err.Error()))
+		return 0
+	}
+	return usage
+}
+
+// reports whether the process was terminated by signal sig
+func killedBy(state *os.ProcessState, sig syscall.Signal) bool {
+	if state == nil {
+		return false
+	}
+	status, ok := state.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == sig
+}
@@ -0,0 +1,125 @@
+// +build linux
+// +build go1.15
+
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/justice-oj/sandbox/model"
+)
+
+// interaction is an interactor process whose stdin and stdout are cross-wired with /Main
+type interaction struct {
+	cmd   *exec.Cmd
+	dir   string
+	timer *time.Timer
+	// set to 1 once the timer has killed the interactor
+	timedOut   int32
+	timeout    int64
+	mainStdin  *os.File
+	mainStdout *os.File
+}
+
+// starts the interactor outside the sandbox, mainStdin and mainStdout are left for /Main
+func startInteractor(path string, tc model.TestCase, timeout int64) (*interaction, error) {
+	dir, err := ioutil.TempDir("", "justice-interactor-")
+	if err != nil {
+		return nil, err
+	}
+
+	input, expected := filepath.Join(dir, "input"), filepath.Join(dir, "expected")
+	if tc.InputFile != "" {
+		input = tc.InputFile
+	} else if err := ioutil.WriteFile(input, []byte(tc.Input), 0644); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := ioutil.WriteFile(expected, []byte(tc.Expected), 0644); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	// interactor -> /Main, /Main -> interactor
+	mainStdin, interactorStdout, err := os.Pipe()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	interactorStdin, mainStdout, err := os.Pipe()
+	if err != nil {
+		_, _ = mainStdin.Close(), interactorStdout.Close()
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	ia := &interaction{dir: dir, timeout: timeout, mainStdin: mainStdin, mainStdout: mainStdout}
+	ia.cmd = exec.Command(path, input, expected)
+	ia.cmd.Stdin = interactorStdin
+	ia.cmd.Stdout = interactorStdout
+	ia.cmd.Stderr = os.Stderr
+	ia.cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	err = ia.cmd.Start()
+	_, _ = interactorStdin.Close(), interactorStdout.Close()
+	if err != nil {
+		ia.closeMainEnds()
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	ia.timer = time.AfterFunc(time.Duration(timeout)*time.Millisecond, func() {
+		atomic.StoreInt32(&ia.timedOut, 1)
+		_ = syscall.Kill(-ia.cmd.Process.Pid, syscall.SIGKILL)
+	})
+	return ia, nil
+}
+
+// closes our copies of the pipe ends meant for /Main, otherwise the interactor never sees EOF
+func (ia *interaction) closeMainEnds() {
+	_, _ = ia.mainStdin.Close(), ia.mainStdout.Close()
+}
+
+func (ia *interaction) kill() {
+	_ = syscall.Kill(-ia.cmd.Process.Pid, syscall.SIGKILL)
+	_ = ia.wait()
+}
+
+func (ia *interaction) wait() error {
+	defer func() { _ = os.RemoveAll(ia.dir) }()
+	defer ia.timer.Stop()
+	return ia.cmd.Wait()
+}
+
+// waits for the interactor and takes its exit code as the verdict of a clean run of /Main:
+// 0 means accepted, 1 means wrong answer, anything else is a failure of the interactor
+func (ia *interaction) judge(r *model.Result) *model.Result {
+	err := ia.wait()
+	if r.Status != model.StatusAc {
+		return r
+	}
+	r.Output = ""
+
+	if err == nil {
+		return r
+	}
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("interactor: %s\n", err.Error()))
+
+	if atomic.LoadInt32(&ia.timedOut) == 1 {
+		return r.GetTimeLimitExceededErrorTaskResult(ia.timeout)
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return r.GetWrongAnswerTaskResult("", "", "")
+	}
+	r.Reason = "interactor failure"
+	return r.GetRuntimeErrorTaskResult()
+}
@@ -0,0 +1,433 @@
+// +build linux
+// +build go1.15
+
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/pkg/reexec"
+	"github.com/justice-oj/sandbox/checker"
+	"github.com/justice-oj/sandbox/model"
+	"github.com/justice-oj/sandbox/sandbox"
+	"github.com/satori/go.uuid"
+)
+
+// RunSpec describes a run of a compiled submission against its test cases, see the flags of clike_container
+type RunSpec struct {
+	// root of the container holding the artifact
+	Basedir string
+	// command run in the container, /Main if empty
+	Command []string
+	Cases   []model.TestCase
+	// stop at the first test case which is not accepted
+	StopOnFailure bool
+	// wall time limit in ms
+	Timeout int64
+	// CPU time limit in ms, 0 means only the wall time limit applies
+	CPUTimeout int64
+	// output limitation of stdout and stderr in KB
+	OutputLimit int64
+	// cgroup limitations shared by every test case
+	Limits sandbox.Limits
+	// judges clean runs unless Interactor is given, the exact checker if nil
+	Checker checker.Checker
+	// the input of every test case is handed to Checker, e.g. a special judge
+	CheckerNeedsInput bool
+	// interactor binary with abs path, invoked as `interactor <input> <expected>`, enables interactive mode
+	Interactor string
+	// JSON seccomp profile with abs path, see profiles/seccomp/default.json
+	Seccomp string
+	// run every test case in a disposable overlay of OverlaySize MB, Basedir itself stays read-only
+	Overlay     bool
+	OverlaySize int64
+	// system dirs in the root of the container, runtime dirs need Overlay
+	Mounts sandbox.Mounts
+	// ids /Main runs as inside the container, mapped to HostUID and HostGID unless 0
+	UID, GID         int
+	HostUID, HostGID int
+	// freeze /Main once it hits a limit and attach its tasks' status, stack and maps to the result
+	Debug bool
+	// bring lo up in the network namespace of the container, so that /Main can use 127.0.0.1
+	Loopback bool
+	// trusted service binary with abs path, started in the network namespace of the container before the first
+	// test case, implies Loopback
+	Companion string
+	// TCP port on 127.0.0.1 which Companion is waited for to listen on, 0 means no waiting
+	CompanionPort int
+}
+
+// DefaultRunSpec returns the defaults of clike_container for the artifact in basedir, short of the test cases:
+// a wall time limit of 2s, 256MB, 10% of a CPU, 64 tasks and 8MB of output
+//noinspection GoUnusedExportedFunction
+func DefaultRunSpec(basedir string) RunSpec {
+	return RunSpec{
+		Basedir:     basedir,
+		Timeout:     2000,
+		OutputLimit: 8192,
+		Limits:      sandbox.DefaultLimits(256),
+		OverlaySize: 16,
+	}
+}
+
+func (spec RunSpec) validate() error {
+	switch {
+	case spec.Timeout <= 0:
+		return fmt.Errorf("wall time limit must be positive, got %d", spec.Timeout)
+	case spec.CPUTimeout < 0:
+		return fmt.Errorf("CPU time limit must not be negative, got %d", spec.CPUTimeout)
+	case spec.OutputLimit <= 0:
+		return fmt.Errorf("output limitation must be positive, got %d", spec.OutputLimit)
+	case spec.Overlay && spec.OverlaySize <= 0:
+		return fmt.Errorf("overlay size must be positive, got %d", spec.OverlaySize)
+	case !spec.Overlay && len(spec.Mounts.Runtime) > 0:
+		return fmt.Errorf("runtime dirs need Overlay, their mount points would be left in Basedir")
+	}
+	return spec.Limits.Validate()
+}
+
+// Run runs the command of spec against its test cases one by one in a single container, returning a model.Result
+// per test case run. A test case which could not be run ends the run with a Runtime Error,
+// an error means spec is invalid, see DefaultRunSpec, the sandbox failed before answering the first test case
+// or ctx was done first.
+//noinspection GoUnusedExportedFunction
+func Run(ctx context.Context, spec RunSpec) ([]*model.Result, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	command := spec.Command
+	if len(command) == 0 {
+		command = []string{"/Main"}
+	}
+	c := spec.Checker
+	if c == nil {
+		c, _ = checker.New("exact", 0, "")
+	}
+	testCases := append([]model.TestCase(nil), spec.Cases...)
+	if len(testCases) == 0 {
+		return nil, nil
+	}
+
+	// justiceInit joins the cgroups itself, whatever is left in them is killed once it is gone
+	containerID := uuid.NewV4().String()
+	defer func() { _ = sandbox.RemoveCGroup(containerID) }()
+
+	// one end of the control socket goes to justiceInit as fd 3
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, &Error{Op: "syscall.Socketpair", Err: err}
+	}
+	control, childControl := os.NewFile(uintptr(fds[0]), "control"), os.NewFile(uintptr(fds[1]), "control")
+	defer func() { _ = control.Close() }()
+
+	// mount point of the overlay stage, stays empty on the host
+	var overlayStage string
+	if spec.Overlay {
+		if overlayStage, err = ioutil.TempDir("", "justice-overlay-"); err != nil {
+			_ = childControl.Close()
+			return nil, err
+		}
+		defer func() { _ = os.Remove(overlayStage) }()
+	}
+
+	job := initJob{
+		Basedir:      spec.Basedir,
+		ContainerID:  containerID,
+		Command:      command,
+		Timeout:      spec.Timeout,
+		CPUTimeout:   spec.CPUTimeout,
+		OutputLimit:  spec.OutputLimit,
+		Limits:       spec.Limits,
+		Interactive:  spec.Interactor != "",
+		Seccomp:      spec.Seccomp,
+		OverlayStage: overlayStage,
+		OverlaySize:  spec.OverlaySize,
+		Mounts:       spec.Mounts,
+		UID:          spec.UID,
+		GID:          spec.GID,
+		Debug:        spec.Debug,
+	}
+	cmd := reexec.Command("justiceInit")
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{childControl}
+	cmd.SysProcAttr = namespaceAttr()
+	// an unprivileged identity inside the container takes ids of its own on the host
+	if spec.UID != 0 {
+		cmd.SysProcAttr.UidMappings = append(cmd.SysProcAttr.UidMappings, syscall.SysProcIDMap{ContainerID: spec.UID, HostID: spec.HostUID, Size: 1})
+	}
+	if spec.GID != 0 {
+		cmd.SysProcAttr.GidMappings = append(cmd.SysProcAttr.GidMappings, syscall.SysProcIDMap{ContainerID: spec.GID, HostID: spec.HostGID, Size: 1})
+	}
+	// sandbox.DropPrivileges sheds the supplementary groups of root, which is denied unless enabled here
+	cmd.SysProcAttr.GidMappingsEnableSetgroups = spec.UID != 0 || spec.GID != 0
+
+	stdin, _ := cmd.StdinPipe()
+	stdout, _ := cmd.StdoutPipe()
+	err = cmd.Start()
+	_ = childControl.Close()
+	if err != nil {
+		return nil, &Error{Op: "justiceInit", Err: err}
+	}
+	stopWatching := killOnDone(ctx, cmd)
+	defer stopWatching()
+
+	// the job goes first on stdin of justiceInit, followed by the test cases
+	encoder, decoder := json.NewEncoder(stdin), json.NewDecoder(stdout)
+	if err := encoder.Encode(job); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, &Error{Op: "justiceInit", Err: err}
+	}
+
+	if spec.Loopback || spec.Companion != "" {
+		cp, err := startNetwork(cmd.Process.Pid, spec.Companion, spec.CompanionPort)
+		if err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return nil, &Error{Op: "startNetwork", Err: err}
+		}
+		if cp != nil {
+			defer func() {
+				_ = syscall.Kill(-cp.Process.Pid, syscall.SIGKILL)
+				_ = cp.Wait()
+			}()
+		}
+	}
+
+	// a special judge or an interactor reads the input once more after /Main, so stdin is spooled to disk first
+	needsInput := spec.CheckerNeedsInput || spec.Interactor != ""
+	if testCases[0].InputFile == "-" && needsInput {
+		if spool, err := spoolStdin(); err != nil {
+			// nothing is run, which is reported as Runtime Error
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("spoolStdin() failed, err: %s\n", err.Error()))
+			testCases = nil
+		} else {
+			defer func() { _ = os.Remove(spool) }()
+			testCases[0].InputFile = spool
+		}
+	}
+
+	results := make([]*model.Result, 0, len(testCases))
+	// set once justiceInit gives up before answering the first test case, e.g. failing to set up the namespaces
+	var initErr error
+	for _, tc := range testCases {
+		r := new(model.Result)
+
+		var ia *interaction
+		if spec.Interactor != "" {
+			if ia, err = startInteractor(spec.Interactor, tc, spec.Timeout); err != nil {
+				_, _ = os.Stderr.WriteString(fmt.Sprintf("startInteractor(%s) failed, err: %s\n", spec.Interactor, err.Error()))
+				results = append(results, r.GetRuntimeErrorTaskResult())
+				break
+			}
+			err = sandbox.SendFiles(control, ia.mainStdin, ia.mainStdout)
+			ia.closeMainEnds()
+			if err != nil {
+				_, _ = os.Stderr.WriteString(fmt.Sprintf("sandbox.SendFiles() failed, err: %s\n", err.Error()))
+				ia.kill()
+				results = append(results, r.GetRuntimeErrorTaskResult())
+				break
+			}
+		} else if tc.InputFile != "" {
+			if err := sendInput(control, tc.InputFile); err != nil {
+				_, _ = os.Stderr.WriteString(fmt.Sprintf("sendInput(%s) failed, err: %s\n", tc.InputFile, err.Error()))
+				results = append(results, r.GetRuntimeErrorTaskResult())
+				break
+			}
+		}
+
+		if err := encoder.Encode(tc); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("encoder.Encode() failed, err: %s\n", err.Error()))
+			if ia != nil {
+				ia.kill()
+			}
+			if len(results) == 0 {
+				initErr = err
+				break
+			}
+			results = append(results, r.GetRuntimeErrorTaskResult())
+			break
+		}
+		if err := decoder.Decode(r); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("decoder.Decode() failed, err: %s\n", err.Error()))
+			if ia != nil {
+				ia.kill()
+			}
+			if len(results) == 0 {
+				initErr = err
+				break
+			}
+			results = append(results, new(model.Result).GetRuntimeErrorTaskResult())
+			break
+		}
+
+		if ia != nil {
+			r = ia.judge(r)
+		} else {
+			r = judge(c, r, tc, needsInput)
+		}
+		results = append(results, r)
+		if spec.StopOnFailure && r.Status != model.StatusAc {
+			break
+		}
+	}
+
+	_ = stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("%s\n", err.Error()))
+	}
+
+	// justiceInit was killed, the results so far are not to be trusted
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if initErr != nil {
+		return nil, &Error{Op: "justiceInit", Err: initErr}
+	}
+	return results, nil
+}
+
+// brings lo up in the network namespace of justiceInit and starts the companion there, if any.
+// setns(2) only moves the calling thread, which is locked and thrown away afterwards,
+// so the companion forked from it and the connects of waitListening are the only ones taken into the namespace.
+func startNetwork(pid int, companion string, port int) (*exec.Cmd, error) {
+	type started struct {
+		cmd *exec.Cmd
+		err error
+	}
+	ch := make(chan started, 1)
+
+	go func() {
+		runtime.LockOSThread()
+
+		if err := sandbox.EnterNetworkNamespace(pid); err != nil {
+			ch <- started{err: err}
+			return
+		}
+		if err := sandbox.SetLoopbackUp(); err != nil {
+			ch <- started{err: err}
+			return
+		}
+		if companion == "" {
+			ch <- started{}
+			return
+		}
+
+		cmd := exec.Command(companion)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		// no Pdeathsig, which fires as soon as the thread forking the companion exits
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if err := cmd.Start(); err != nil {
+			ch <- started{err: err}
+			return
+		}
+		if port != 0 {
+			if err := waitListening(port, 5*time.Second); err != nil {
+				_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+				_ = cmd.Wait()
+				ch <- started{err: err}
+				return
+			}
+		}
+		ch <- started{cmd: cmd}
+	}()
+
+	s := <-ch
+	return s.cmd, s.err
+}
+
+// connects to 127.0.0.1:port until it is accepted or the deadline passes, package net is left out
+// since it grows the binary, which also counts against the memory limitation as justiceInit
+func waitListening(port int, timeout time.Duration) error {
+	addr := &syscall.SockaddrInet4{Port: port, Addr: [4]byte{127, 0, 0, 1}}
+	deadline := time.Now().Add(timeout)
+	for {
+		fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+		if err != nil {
+			return err
+		}
+		err = syscall.Connect(fd, addr)
+		_ = syscall.Close(fd)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("127.0.0.1:%d is not listening after %s, err: %s", port, timeout, err.Error())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// copies stdin of the calling process into a temp file, returns its path
+func spoolStdin() (string, error) {
+	f, err := ioutil.TempFile("", "justice-input-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := io.Copy(f, os.Stdin); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// hands the input file over to justiceInit, - stands for stdin of the calling process
+func sendInput(control *os.File, path string) error {
+	if path == "-" {
+		return sandbox.SendFiles(control, os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return sandbox.SendFiles(control, f)
+}
+
+// turns a clean run reported by justiceInit into AC or WA according to the checker, keeping its resource usage.
+// An input file is read only if the checker needs the input, and is never echoed in the result.
+func judge(c checker.Checker, r *model.Result, tc model.TestCase, needsInput bool) *model.Result {
+	if r.Status != model.StatusAc {
+		return r
+	}
+
+	output := r.Output
+	r.Output = ""
+
+	input := tc.Input
+	if tc.InputFile != "" && needsInput {
+		content, err := ioutil.ReadFile(tc.InputFile)
+		if err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("ioutil.ReadFile(%s) failed, err: %s\n", tc.InputFile, err.Error()))
+			return r.GetRuntimeErrorTaskResult()
+		}
+		input = string(content)
+	}
+
+	ok, err := c.Check(input, output, tc.Expected)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("c.Check() failed, err: %s\n", err.Error()))
+		r.Reason = "checker failure"
+		return r.GetRuntimeErrorTaskResult()
+	}
+
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("output: %s | expected: %s\n", strings.TrimSpace(output), tc.Expected))
+	if !ok {
+		return r.GetWrongAnswerTaskResult(tc.Input, strings.TrimSpace(output), tc.Expected)
+	}
+	return r
+}
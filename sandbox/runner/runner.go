@@ -0,0 +1,51 @@
+// +build linux
+// +build go1.15
+
+// Package runner compiles and runs submissions in the sandbox, clike_compiler and clike_container are thin wrappers
+// around Compile and Run.
+//
+// Both re-execute the calling binary as "justiceCompile" or "justiceInit" inside new namespaces. Importing this
+// package is enough to have those handled: its init() takes over the process whenever it is started under one of
+// these names and exits once done, so neither main() nor anything registered after this package ever runs there.
+package runner
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/docker/docker/pkg/reexec"
+)
+
+func init() {
+	/**
+	* 0. `init()` adds keys "justiceInit" and "justiceCompile" in `map`;
+	* 1. reexec.Init() seeks if key `os.Args[0]` exists in `registeredInitializers`;
+	* 2. for the first time the binary is invoked, the key is os.Args[0], e.g. "/path/to/clike_container",
+	     which `registeredInitializers` will return `false`;
+	* 3. Run() or Compile() calls the binary itself by reexec.Command("justiceInit"), handing the job over as JSON on stdin;
+	* 4. for the second time the binary is invoked, the key is os.Args[0], AKA "justiceInit",
+	*    which exists in `registeredInitializers`;
+	* 5. the value `justiceInit()` is invoked, any hooks(like set hostname) before fork() can be placed here.
+	*/
+	reexec.Register("justiceInit", justiceInit)
+	reexec.Register("justiceCompile", justiceCompile)
+	if reexec.Init() {
+		os.Exit(0)
+	}
+}
+
+// Error is returned once the sandbox itself fails, as opposed to the program run in it,
+// whose failures are reported by the results
+type Error struct {
+	// what failed, e.g. "justiceInit"
+	Op  string
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s failed, err: %s", e.Op, e.Err.Error())
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
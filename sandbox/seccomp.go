@@ -0,0 +1,135 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// https://www.kernel.org/doc/Documentation/userspace-api/seccomp_filter.rst
+const (
+	seccompModeFilter     = 2
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+
+	// offsetof(struct seccomp_data, nr) and offsetof(struct seccomp_data, arch)
+	seccompDataNrOffset   = 0
+	seccompDataArchOffset = 4
+
+	prSetNoNewPrivs = 38
+)
+
+// SeccompProfile is the JSON representation of a syscall allow-list, e.g.
+//   {"default_action": "kill", "syscalls": ["read", "write", "exit_group"]}
+// With "kill" a disallowed syscall terminates the process with SIGSYS,
+// with "errno" it fails with EPERM instead.
+// The profile must also allow what the Go runtime needs to fork and exec /Main,
+// see profiles/seccomp/default.json.
+type SeccompProfile struct {
+	DefaultAction string   `json:"default_action"`
+	Syscalls      []string `json:"syscalls"`
+}
+
+// SeccompFilter is a compiled SeccompProfile
+type SeccompFilter struct {
+	program []syscall.SockFilter
+}
+
+//noinspection GoUnusedExportedFunction
+func LoadSeccompProfile(path string) (*SeccompFilter, error) {
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("LoadSeccompProfile(%s) starting...\n", path))
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("ioutil.ReadFile(%s) failed, err: %s\n", path, err.Error()))
+		return nil, err
+	}
+
+	var profile SeccompProfile
+	if err := json.Unmarshal(content, &profile); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("json.Unmarshal() failed, err: %s\n", err.Error()))
+		return nil, err
+	}
+
+	filter, err := profile.compile()
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("profile.compile() failed, err: %s\n", err.Error()))
+		return nil, err
+	}
+
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("LoadSeccompProfile(%s) done, %d syscalls allowed\n", path, len(profile.Syscalls)))
+	return filter, nil
+}
+
+func (p *SeccompProfile) compile() (*SeccompFilter, error) {
+	if len(syscallNumbers) == 0 {
+		return nil, fmt.Errorf("seccomp profiles are not supported on this architecture")
+	}
+
+	var defaultAction uint32
+	switch p.DefaultAction {
+	case "kill", "":
+		defaultAction = seccompRetKillProcess
+	case "errno":
+		defaultAction = seccompRetErrno | uint32(syscall.EPERM)
+	default:
+		return nil, fmt.Errorf("unknown default_action: %s", p.DefaultAction)
+	}
+
+	program := []syscall.SockFilter{
+		// kill syscalls made through any other ABI, numbers below would not match
+		bpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataArchOffset),
+		bpfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, auditArch, 1, 0),
+		bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRetKillProcess),
+		bpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataNrOffset),
+		bpfJump(syscall.BPF_JMP|syscall.BPF_JGE|syscall.BPF_K, x32SyscallBit, 0, 1),
+		bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRetKillProcess),
+	}
+
+	// a pair of instructions per syscall keeps every jump offset within 8 bits
+	for _, name := range p.Syscalls {
+		nr, ok := syscallNumbers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown syscall: %s", name)
+		}
+		program = append(program,
+			bpfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, nr, 0, 1),
+			bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRetAllow),
+		)
+	}
+
+	program = append(program, bpfStmt(syscall.BPF_RET|syscall.BPF_K, defaultAction))
+	return &SeccompFilter{program: program}, nil
+}
+
+// Install applies the filter to the calling thread only, the caller is expected to run
+// on a locked OS thread which forks /Main and is thrown away afterwards.
+func (f *SeccompFilter) Install() error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS) failed, err: %s", errno.Error())
+	}
+
+	prog := syscall.SockFprog{
+		Len:    uint16(len(f.program)),
+		Filter: &f.program[0],
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_SECCOMP, seccompModeFilter, uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_SECCOMP) failed, err: %s", errno.Error())
+	}
+	return nil
+}
+
+func bpfStmt(code uint16, k uint32) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
@@ -0,0 +1,12 @@
+// +build linux
+// +build go1.15
+// +build !amd64
+
+package sandbox
+
+// seccomp profiles are only supported on x86_64 for now
+const auditArch = 0
+
+const x32SyscallBit = 0
+
+var syscallNumbers = map[string]uint32{}
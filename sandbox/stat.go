@@ -0,0 +1,72 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// cgroupStat keeps a cgroup file open, so that it can still be read after pivot_root has hidden /sys/fs/cgroup.
+// The file either holds a single number, or is a flat keyed file like memory.events if key is given.
+type cgroupStat struct {
+	file *os.File
+	key  string
+}
+
+func openCGroupStat(path, key string) (*cgroupStat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("os.Open(%s) failed, err: %s\n", path, err.Error()))
+		return nil, err
+	}
+	return &cgroupStat{file: f, key: key}, nil
+}
+
+// opens a single number file which is reset by writing value to it, e.g. memory.max_usage_in_bytes
+func openResettableCGroupStat(path string) (*cgroupStat, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("os.OpenFile(%s, os.O_RDWR) failed, err: %s\n", path, err.Error()))
+		return nil, err
+	}
+	return &cgroupStat{file: f}, nil
+}
+
+func (s *cgroupStat) read() (int64, error) {
+	if _, err := s.file.Seek(0, 0); err != nil {
+		return 0, err
+	}
+	content, err := ioutil.ReadAll(s.file)
+	if err != nil {
+		return 0, err
+	}
+
+	if s.key == "" {
+		return strconv.ParseInt(string(bytes.TrimSpace(content)), 10, 64)
+	}
+
+	// flat keyed file, e.g. "oom_kill 1"
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) == 2 && string(fields[0]) == s.key {
+			return strconv.ParseInt(string(fields[1]), 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("key %s not found in %s", s.key, s.file.Name())
+}
+
+func (s *cgroupStat) reset(value string) error {
+	_, err := s.file.WriteAt([]byte(value), 0)
+	return err
+}
+
+func (s *cgroupStat) close() error {
+	return s.file.Close()
+}
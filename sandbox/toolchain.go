@@ -0,0 +1,171 @@
+// +build linux
+// +build go1.15
+
+package sandbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// DefaultToolchain are the dirs of the host bind-mounted read-only into the root of the compiler
+var DefaultToolchain = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/usr", "/etc/alternatives"}
+
+// device nodes of the host shared with the compiler, e.g. `#include </dev/random>` must hang rather than fail
+var compilerDevices = []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"}
+
+// InitCompilerNamespace builds a disposable root for the compiler on a tmpfs at newRoot and pivots into it.
+// The root holds read-only bind mounts of the toolchain dirs, a few device nodes, /proc, a tmpfs /tmp of tmpSize MB
+// and workdir mounted at /work, which is the only place the compiler can write to.
+//noinspection GoUnusedExportedFunction
+func InitCompilerNamespace(newRoot, workdir string, toolchain []string, tmpSize int64) error {
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitCompilerNamespace(%s, %s, %v, %d) starting...\n", newRoot, workdir, toolchain, tmpSize))
+
+	if err := makeMountsPrivate(); err != nil {
+		return err
+	}
+
+	if err := syscall.Mount("tmpfs", newRoot, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "size=1m,mode=0755"); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("syscall.Mount(tmpfs, %s) failed, err: %s\n", newRoot, err.Error()))
+		return err
+	}
+
+	for _, dir := range toolchain {
+		if err := mountToolchainDir(newRoot, dir); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("mountToolchainDir(%s, %s) failed, err: %s\n", newRoot, dir, err.Error()))
+			return err
+		}
+	}
+
+	for _, device := range compilerDevices {
+		if err := bindMount(device, filepath.Join(newRoot, device), false); err != nil {
+			_, _ = os.Stderr.WriteString(fmt.Sprintf("bindMount(%s) failed, err: %s\n", device, err.Error()))
+			return err
+		}
+	}
+
+	if err := bindMount(workdir, filepath.Join(newRoot, "work"), true); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("bindMount(%s) failed, err: %s\n", workdir, err.Error()))
+		return err
+	}
+
+	// a procfs of our own pid namespace, some toolchains (e.g. go) look up /proc/self/exe
+	proc := filepath.Join(newRoot, "proc")
+	if err := os.MkdirAll(proc, 0555); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("os.MkdirAll(%s, 0555) failed, err: %s\n", proc, err.Error()))
+		return err
+	}
+	if err := syscall.Mount("proc", proc, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("syscall.Mount(proc, %s) failed, err: %s\n", proc, err.Error()))
+		return err
+	}
+
+	tmp := filepath.Join(newRoot, "tmp")
+	if err := os.MkdirAll(tmp, 01777); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("os.MkdirAll(%s, 01777) failed, err: %s\n", tmp, err.Error()))
+		return err
+	}
+	if err := syscall.Mount("tmpfs", tmp, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, fmt.Sprintf("size=%dm,mode=1777", tmpSize)); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("syscall.Mount(tmpfs, %s) failed, err: %s\n", tmp, err.Error()))
+		return err
+	}
+
+	if err := pivotRoot(newRoot); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("pivotRoot(%s) failed, err: %s\n", newRoot, err.Error()))
+		return err
+	}
+
+	if err := syscall.Sethostname([]byte("justice")); err != nil {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("syscall.Sethostname failed, err: %s\n", err.Error()))
+		return err
+	}
+
+	_, _ = os.Stderr.WriteString(fmt.Sprintf("InitCompilerNamespace(%s, %s, %v, %d) done\n", newRoot, workdir, toolchain, tmpSize))
+	return nil
+}
+
+// recreates dir of the host under newRoot, a symlink as a symlink (e.g. /bin -> usr/bin on merged /usr)
+// and a directory as a read-only bind mount. Dirs missing on the host are skipped.
+func mountToolchainDir(newRoot, dir string) error {
+	fi, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(newRoot, dir)
+	if fi.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(dir)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	}
+	return bindMount(dir, target, false)
+}
+
+// bind mounts source at target, which is created first, read-only unless writable
+func bindMount(source, target string, writable bool) error {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		err = os.MkdirAll(target, 0755)
+	} else if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+		err = ioutil.WriteFile(target, nil, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return err
+	}
+	if writable {
+		return nil
+	}
+
+	// a bind mount only becomes read-only by remounting, which must keep the flags
+	// locked by the user namespace, e.g. nosuid and nodev of the source
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(source, &st); err != nil {
+		return err
+	}
+	return syscall.Mount("", target, "", syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_RDONLY|lockedFlags(st.Flags), "")
+}
+
+// statfs(2) flags which have to be carried over by a remount in a user namespace
+func lockedFlags(statfsFlags int64) uintptr {
+	const (
+		stNoSuid     = 0x2
+		stNoDev      = 0x4
+		stNoExec     = 0x8
+		stNoAtime    = 0x400
+		stNoDirAtime = 0x800
+		stRelAtime   = 0x1000
+	)
+
+	var flags uintptr
+	for st, ms := range map[int64]uintptr{
+		stNoSuid:     syscall.MS_NOSUID,
+		stNoDev:      syscall.MS_NODEV,
+		stNoExec:     syscall.MS_NOEXEC,
+		stNoAtime:    syscall.MS_NOATIME,
+		stNoDirAtime: syscall.MS_NODIRATIME,
+		stRelAtime:   syscall.MS_RELATIME,
+	} {
+		if statfsFlags&st != 0 {
+			flags |= ms
+		}
+	}
+	return flags
+}
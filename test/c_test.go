@@ -2,12 +2,19 @@ package test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/justice-oj/sandbox/model"
+	"github.com/justice-oj/sandbox/sandbox"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -37,7 +44,7 @@ func copyCSourceFile(name string, t *testing.T) {
 func compileC(name, baseDir string, t *testing.T) string {
 	t.Logf("Compiling file %s ...", name)
 
-	var stderr bytes.Buffer
+	var stdout bytes.Buffer
 	args := []string{
 		"-compiler=/usr/bin/gcc",
 		"-basedir=" + baseDir,
@@ -46,12 +53,12 @@ func compileC(name, baseDir string, t *testing.T) string {
 		"-std=gnu11",
 	}
 	cmd := exec.Command("/opt/justice-sandbox/bin/clike_compiler", args...)
-	cmd.Stderr = &stderr
+	cmd.Stdout = &stdout
 	if err := cmd.Run(); err != nil {
 		t.Errorf("Invoke `/opt/justice-sandbox/bin/clike_compiler %s` err: %v", strings.Join(args, " "), err)
 	}
 
-	return stderr.String()
+	return compileErrorOf(stdout.Bytes(), t)
 }
 
 // run binary in our container
@@ -77,6 +84,99 @@ func runC(baseDir, memory, timeout string, t *testing.T) string {
 	return stdout.String()
 }
 
+// run binary in our container against a set of test cases
+func runCBatch(baseDir, cases string, stopOnFailure bool, t *testing.T) string {
+	t.Log("Running binary /Main in batch mode ...")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{
+		"-basedir=" + baseDir,
+		"-cases=" + CProjectDir + "/resources/cases/" + cases,
+		"-memory=64",
+		"-timeout=1000",
+		fmt.Sprintf("-stop-on-failure=%t", stopOnFailure),
+	}
+	cmd := exec.Command("/opt/justice-sandbox/bin/clike_container", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Invoke `/opt/justice-sandbox/bin/clike_container %s` err: %v", strings.Join(args, " "), err)
+	}
+
+	t.Logf("stderr of runCBatch: %s", stderr.String())
+	return stdout.String()
+}
+
+// run binary in our container with extra flags of clike_container
+func runCWithFlags(baseDir, input, expected string, flags []string, t *testing.T) string {
+	t.Logf("Running binary /Main with %s ...", strings.Join(flags, " "))
+
+	var stdout, stderr bytes.Buffer
+	args := append([]string{
+		"-basedir=" + baseDir,
+		"-input=" + input,
+		"-expected=" + expected,
+		"-memory=64",
+		"-timeout=1000",
+	}, flags...)
+	cmd := exec.Command("/opt/justice-sandbox/bin/clike_container", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Invoke `/opt/justice-sandbox/bin/clike_container %s` err: %v", strings.Join(args, " "), err)
+	}
+
+	t.Logf("stderr of runCWithFlags: %s", stderr.String())
+	return stdout.String()
+}
+
+// run binary in our container with stdin of clike_container given
+func runCWithStdin(baseDir string, stdin io.Reader, flags []string, t *testing.T) string {
+	t.Logf("Running binary /Main with %s and stdin ...", strings.Join(flags, " "))
+
+	var stdout, stderr bytes.Buffer
+	args := append([]string{
+		"-basedir=" + baseDir,
+		"-memory=64",
+		"-timeout=1000",
+	}, flags...)
+	cmd := exec.Command("/opt/justice-sandbox/bin/clike_container", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Invoke `/opt/justice-sandbox/bin/clike_container %s` err: %v", strings.Join(args, " "), err)
+	}
+
+	t.Logf("stderr of runCWithStdin: %s", stderr.String())
+	return stdout.String()
+}
+
+// turns the JSON result of clike_compiler into its error and diagnostics, empty if compiled
+func compileErrorOf(output []byte, t *testing.T) string {
+	r := new(model.CompileResult)
+	if err := json.Unmarshal(output, r); err != nil {
+		t.Errorf("Invoke json.Unmarshal(%s) err: %v", output, err)
+		return string(output)
+	}
+	if r.Status == model.CompileStatusOk {
+		return ""
+	}
+	return r.Error + ": " + r.Diagnostics
+}
+
+// compile trusted helper `*.c` (special judge, interactor) under resources/<kind> into tmp dir, returns its abs path
+func compileHelper(kind, name string, t *testing.T) string {
+	t.Logf("Compiling %s %s ...", kind, name)
+
+	helper := t.TempDir() + "/" + kind
+	args := []string{CProjectDir + "/resources/" + kind + "/" + name, "-o", helper}
+	if output, err := exec.Command("/usr/bin/gcc", args...).CombinedOutput(); err != nil {
+		t.Errorf("Invoke `/usr/bin/gcc %s` err: %v, output: %s", strings.Join(args, " "), err, output)
+	}
+	return helper
+}
+
 func TestC0000Fixture(t *testing.T) {
 	CProjectDir, _ = os.Getwd()
 	CBaseDir = t.TempDir()
@@ -97,7 +197,7 @@ func TestC0002CompilerBomb0(t *testing.T) {
 	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
 		copyCSourceFile(name, t)
 
-		So(compileC(name, CBaseDir, t), ShouldContainSubstring, "signal: killed")
+		So(compileC(name, CBaseDir, t), ShouldContainSubstring, "Compiler Memory Exceeded")
 	})
 }
 
@@ -106,7 +206,7 @@ func TestC0003CompilerBomb1(t *testing.T) {
 	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
 		copyCSourceFile(name, t)
 
-		So(compileC(name, CBaseDir, t), ShouldContainSubstring, "signal: killed")
+		So(compileC(name, CBaseDir, t), ShouldContainSubstring, "Compiler Memory Exceeded")
 	})
 }
 
@@ -115,7 +215,9 @@ func TestC0004CompilerBomb2(t *testing.T) {
 	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
 		copyCSourceFile(name, t)
 
-		So(compileC(name, CBaseDir, t), ShouldContainSubstring, "signal: killed")
+		// whichever limit is hit first stops the compiler
+		output := compileC(name, CBaseDir, t)
+		So(strings.HasPrefix(output, "Compile Timeout") || strings.HasPrefix(output, "Compiler Memory Exceeded"), ShouldBeTrue)
 	})
 }
 
@@ -124,7 +226,7 @@ func TestC0005CompilerBomb3(t *testing.T) {
 	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
 		copyCSourceFile(name, t)
 
-		So(compileC(name, CBaseDir, t), ShouldContainSubstring, "signal: killed")
+		So(compileC(name, CBaseDir, t), ShouldContainSubstring, "Compiler Memory Exceeded")
 	})
 }
 
@@ -145,7 +247,10 @@ func TestC0007CoreDump1(t *testing.T) {
 
 		// warning: division by zero [-Wdiv-by-zero]
 		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
-		So(runC(CBaseDir, "64", "1000", t), ShouldContainSubstring, "Runtime Error")
+		output := runC(CBaseDir, "64", "1000", t)
+		So(output, ShouldContainSubstring, "Runtime Error")
+		So(output, ShouldContainSubstring, `"signal":"SIGFPE"`)
+		So(output, ShouldContainSubstring, `"reason":"division by zero"`)
 	})
 }
 
@@ -156,7 +261,9 @@ func TestC0008CoreDump2(t *testing.T) {
 
 		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
 		// *** stack smashing detected ***: terminated
-		So(runC(CBaseDir, "64", "1000", t), ShouldContainSubstring, "Runtime Error")
+		// or TLE, depending on the stack layout the loop counter itself might be overwritten
+		output := runC(CBaseDir, "64", "1000", t)
+		So(strings.Contains(output, "Runtime Error") || strings.Contains(output, "Time Limit Exceeded"), ShouldBeTrue)
 	})
 }
 
@@ -166,8 +273,8 @@ func TestC0009ForkBomb0(t *testing.T) {
 		copyCSourceFile(name, t)
 
 		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
-		// got `signal: killed`
-		So(runC(CBaseDir, "64", "1000", t), ShouldContainSubstring, "Runtime Error")
+		// killed by the watchdog
+		So(runC(CBaseDir, "64", "1000", t), ShouldContainSubstring, "Time Limit Exceeded")
 	})
 }
 
@@ -200,7 +307,10 @@ func TestC0012IncludeLeaks(t *testing.T) {
 	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
 		copyCSourceFile(name, t)
 
-		So(compileC(name, CBaseDir, t), ShouldContainSubstring, "/etc/shadow")
+		// the compiler only sees the toolchain, /etc/shadow of the host is not there to be read
+		stderr := compileC(name, CBaseDir, t)
+		So(stderr, ShouldContainSubstring, "/etc/shadow")
+		So(stderr, ShouldContainSubstring, "No such file or directory")
 	})
 }
 
@@ -210,8 +320,8 @@ func TestC0013InfiniteLoop(t *testing.T) {
 		copyCSourceFile(name, t)
 
 		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
-		// got `signal: killed`
-		So(runC(CBaseDir, "64", "1000", t), ShouldContainSubstring, "Runtime Error")
+		// killed by the watchdog
+		So(runC(CBaseDir, "64", "1000", t), ShouldContainSubstring, "Time Limit Exceeded")
 	})
 }
 
@@ -221,7 +331,7 @@ func TestC0014MemoryAllocation(t *testing.T) {
 		copyCSourceFile(name, t)
 
 		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
-		So(runC(CBaseDir, "8", "5000", t), ShouldContainSubstring, "Runtime Error")
+		So(runC(CBaseDir, "8", "5000", t), ShouldContainSubstring, "Memory Limit Exceeded")
 	})
 }
 
@@ -273,3 +383,415 @@ func TestC0019TCPClient(t *testing.T) {
 		So(runC(CBaseDir, "16", "5000", t), ShouldContainSubstring, "Runtime Error")
 	})
 }
+
+func TestC0020BatchDirectory(t *testing.T) {
+	name := "ac.c"
+	Convey(fmt.Sprintf("Testing [%s] in batch mode...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		output := runCBatch(CBaseDir, "time_conversion", false, t)
+		So(output, ShouldStartWith, "[")
+		So(strings.Count(output, `"status":0`), ShouldEqual, 3)
+	})
+}
+
+func TestC0021BatchManifest(t *testing.T) {
+	name := "ac.c"
+	Convey(fmt.Sprintf("Testing [%s] in batch mode...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		output := runCBatch(CBaseDir, "time_conversion_wa.json", false, t)
+		So(strings.Count(output, `"status":0`), ShouldEqual, 2)
+		So(strings.Count(output, `"status":5`), ShouldEqual, 1)
+	})
+}
+
+func TestC0022BatchStopOnFailure(t *testing.T) {
+	name := "ac.c"
+	Convey(fmt.Sprintf("Testing [%s] in batch mode...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		output := runCBatch(CBaseDir, "time_conversion_wa.json", true, t)
+		So(strings.Count(output, `"status":0`), ShouldEqual, 1)
+		So(strings.Count(output, `"status":5`), ShouldEqual, 1)
+	})
+}
+
+func TestC0023OutputFlood(t *testing.T) {
+	name := "output_flood.c"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runC(CBaseDir, "64", "5000", t), ShouldContainSubstring, "Output Limit Exceeded")
+	})
+}
+
+func TestC0024TokenChecker(t *testing.T) {
+	name := "scattered_tokens.c"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "", "1 2 3 4", []string{"-checker=exact"}, t), ShouldContainSubstring, `"status":5`)
+		So(runCWithFlags(CBaseDir, "", "1 2 3 4", []string{"-checker=token"}, t), ShouldContainSubstring, `"status":0`)
+		So(runCWithFlags(CBaseDir, "", "1 2 3", []string{"-checker=token"}, t), ShouldContainSubstring, `"status":5`)
+	})
+}
+
+func TestC0025FloatChecker(t *testing.T) {
+	name := "float_division.c"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "1 3", "0.333333", []string{"-checker=exact"}, t), ShouldContainSubstring, `"status":5`)
+		So(runCWithFlags(CBaseDir, "1 3", "0.333333", []string{"-checker=float", "-epsilon=1e-6"}, t), ShouldContainSubstring, `"status":0`)
+		So(runCWithFlags(CBaseDir, "1 3", "0.3333", []string{"-checker=float", "-epsilon=1e-6"}, t), ShouldContainSubstring, `"status":5`)
+	})
+}
+
+func TestC0026SpecialJudge(t *testing.T) {
+	name := "yes.c"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyCSourceFile(name, t)
+		spj := compileHelper("spj", "case_insensitive.c", t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "", "yes", []string{"-checker=spj", "-spj=" + spj}, t), ShouldContainSubstring, `"status":0`)
+		So(runCWithFlags(CBaseDir, "", "no", []string{"-checker=spj", "-spj=" + spj}, t), ShouldContainSubstring, `"status":5`)
+	})
+}
+
+func TestC0027Interactor(t *testing.T) {
+	name := "guess_number.c"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyCSourceFile(name, t)
+		interactor := compileHelper("interactor", "guess_number.c", t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "1", "", []string{"-interactor=" + interactor}, t), ShouldContainSubstring, `"status":0`)
+		So(runCWithFlags(CBaseDir, "766432", "", []string{"-interactor=" + interactor}, t), ShouldContainSubstring, `"status":0`)
+	})
+}
+
+func TestC0028InteractorWrongAnswer(t *testing.T) {
+	name := "guess_number_linear.c"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyCSourceFile(name, t)
+		interactor := compileHelper("interactor", "guess_number.c", t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "5", "", []string{"-interactor=" + interactor}, t), ShouldContainSubstring, `"status":0`)
+		So(runCWithFlags(CBaseDir, "766432", "", []string{"-interactor=" + interactor}, t), ShouldContainSubstring, `"status":5`)
+	})
+}
+
+func TestC0029InteractorTimeLimitExceeded(t *testing.T) {
+	name := "infinite_loop.c"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyCSourceFile(name, t)
+		interactor := compileHelper("interactor", "guess_number.c", t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "5", "", []string{"-interactor=" + interactor}, t), ShouldContainSubstring, "Time Limit Exceeded")
+	})
+}
+
+func TestC0030SeccompAC(t *testing.T) {
+	name := "ac.c"
+	Convey(fmt.Sprintf("Testing [%s] with seccomp...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "10:10:23PM", "22:10:23", []string{"-seccomp=" + CProjectDir + "/../profiles/seccomp/default.json"}, t), ShouldContainSubstring, `"status":0`)
+	})
+}
+
+func TestC0031SeccompPtrace(t *testing.T) {
+	name := "ptrace.c"
+	Convey(fmt.Sprintf("Testing [%s] with seccomp...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "", "", []string{"-seccomp=" + CProjectDir + "/../profiles/seccomp/default.json"}, t), ShouldContainSubstring, "Restricted Function")
+	})
+}
+
+func TestC0032SeccompKill(t *testing.T) {
+	name := "syscall_0.c"
+	Convey(fmt.Sprintf("Testing [%s] with seccomp...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "", "", []string{"-seccomp=" + CProjectDir + "/../profiles/seccomp/default.json"}, t), ShouldContainSubstring, "Restricted Function")
+	})
+}
+
+func TestC0033CPUTimeLimit(t *testing.T) {
+	name := "infinite_loop.c"
+	Convey(fmt.Sprintf("Testing [%s] with CPU time limit...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		// wall time limit is overridden, a 10% CPU quota burns 200ms of CPU time in about 2s
+		So(runCWithFlags(CBaseDir, "", "", []string{"-timeout=10000", "-cpu-timeout=200"}, t), ShouldContainSubstring, "Time Limit Exceeded")
+	})
+}
+
+func TestC0034InputFile(t *testing.T) {
+	name := "ac.c"
+	Convey(fmt.Sprintf("Testing [%s] with input and expected files...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "", "", []string{
+			"-input-file=" + CProjectDir + "/resources/cases/time_conversion/0.in",
+			"-expected-file=" + CProjectDir + "/resources/cases/time_conversion/0.out",
+		}, t), ShouldContainSubstring, `"status":0`)
+	})
+}
+
+func TestC0035InputStdin(t *testing.T) {
+	name := "byte_count.c"
+	Convey(fmt.Sprintf("Testing [%s] with large binary input from stdin...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		// 64MB of NUL bytes exceed the memory limit of /Main, so they must be streamed
+		stdin := io.LimitReader(zeroReader{}, 64<<20)
+		So(runCWithStdin(CBaseDir, stdin, []string{"-input-file=-", "-expected=67108864", "-memory=16"}, t), ShouldContainSubstring, `"status":0`)
+	})
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestC0036IncludeHostFile(t *testing.T) {
+	name := "include_passwd.c"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		// the compiler only sees the toolchain, /etc/passwd of the host must not show up in the diagnostics
+		stderr := compileC(name, CBaseDir, t)
+		So(stderr, ShouldContainSubstring, "No such file or directory")
+		So(stderr, ShouldNotContainSubstring, "root:")
+	})
+}
+
+func TestC0037CompileResult(t *testing.T) {
+	Convey("Testing JSON result of clike_compiler...", t, func() {
+		copyCSourceFile("ac.c", t)
+
+		output, err := exec.Command("/opt/justice-sandbox/bin/clike_compiler", "-basedir="+CBaseDir, "-timeout=3000").Output()
+		So(err, ShouldBeNil)
+		r := new(model.CompileResult)
+		So(json.Unmarshal(output, r), ShouldBeNil)
+		So(r.Status, ShouldEqual, model.CompileStatusOk)
+		So(r.ExitCode, ShouldEqual, 0)
+		So(r.Runtime, ShouldBeGreaterThan, 0)
+		So(r.Memory, ShouldBeGreaterThan, 0)
+
+		copyCSourceFile("plain_text.c", t)
+
+		output, err = exec.Command("/opt/justice-sandbox/bin/clike_compiler", "-basedir="+CBaseDir, "-timeout=3000").Output()
+		So(err, ShouldBeNil)
+		r = new(model.CompileResult)
+		So(json.Unmarshal(output, r), ShouldBeNil)
+		So(r.Status, ShouldEqual, model.CompileStatusError)
+		So(r.Error, ShouldEqual, "Compile Error")
+		So(r.ExitCode, ShouldEqual, 1)
+		So(r.Diagnostics, ShouldContainSubstring, "error: unknown type name")
+	})
+}
+
+func TestC0038ResourceUsage(t *testing.T) {
+	name := "core_dump_0.c"
+	Convey(fmt.Sprintf("Testing resource usage of [%s]...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		r := new(model.Result)
+		So(json.Unmarshal([]byte(runC(CBaseDir, "64", "1000", t)), r), ShouldBeNil)
+		So(r.Status, ShouldEqual, model.StatusRe)
+		So(r.Signal, ShouldEqual, "SIGSEGV")
+		So(r.ExitCode, ShouldEqual, -1)
+		So(r.Usage, ShouldNotBeNil)
+		So(r.Usage.MaxRSS, ShouldBeGreaterThan, 0)
+		So(r.Usage.MinorPageFaults, ShouldBeGreaterThan, 0)
+	})
+}
+
+func TestC0039OverlayRoot(t *testing.T) {
+	name := "marker.c"
+	Convey(fmt.Sprintf("Testing [%s] in overlay roots...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		// every test case starts from a pristine root, basedir itself is left untouched
+		output := runCWithFlags(CBaseDir, "", "", []string{"-cases=" + CProjectDir + "/resources/cases/marker.json", "-overlay"}, t)
+		So(output, ShouldStartWith, `[{"runtime"`)
+		So(output, ShouldNotContainSubstring, `"status":5`)
+		_, err := os.Stat(CBaseDir + "/marker")
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+}
+
+func TestC0040OverlaySize(t *testing.T) {
+	name := "fill_disk.c"
+	Convey(fmt.Sprintf("Testing [%s] in overlay root...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "", "full", []string{"-overlay", "-overlay-size=8"}, t), ShouldContainSubstring, `"status":0`)
+	})
+}
+
+func TestC0041SystemDirs(t *testing.T) {
+	name := "system_dirs.c"
+	Convey(fmt.Sprintf("Testing [%s] with system dirs...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "", "- - -", []string{}, t), ShouldContainSubstring, `"status":0`)
+		So(runCWithFlags(CBaseDir, "", "dev tmp proc", []string{"-dev", "-proc", "-tmp-size=1"}, t), ShouldContainSubstring, `"status":0`)
+		So(runCWithFlags(CBaseDir, "", "dev tmp proc", []string{"-dev", "-proc", "-tmp-size=1", "-overlay"}, t), ShouldContainSubstring, `"status":0`)
+	})
+}
+
+func TestC0042Privileges(t *testing.T) {
+	name := "privileges.c"
+	Convey(fmt.Sprintf("Testing [%s] without privileges...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runCWithFlags(CBaseDir, "", "uid 0 gid 0 caps 0 bounding 0 nnp 1", []string{}, t), ShouldContainSubstring, `"status":0`)
+		So(runCWithFlags(CBaseDir, "", "uid 1000 gid 1000 caps 0 bounding 0 nnp 1", []string{"-uid=1000", "-gid=1000"}, t), ShouldContainSubstring, `"status":0`)
+	})
+}
+
+// names of the cgroups of containers in the pids hierarchy
+func containerCGroups(t *testing.T) []string {
+	root := "/sys/fs/cgroup/pids"
+	if sandbox.IsCGroupV2() {
+		root = "/sys/fs/cgroup"
+	}
+
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Errorf("Invoke ioutil.ReadDir(%s) err: %v", root, err)
+	}
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && pattern.MatchString(entry.Name()) {
+			names = append(names, filepath.Join(root, entry.Name()))
+		}
+	}
+	return names
+}
+
+func TestC0043CGroupCleanup(t *testing.T) {
+	name := "fork_bomb_0.c"
+	Convey(fmt.Sprintf("Testing [%s] leaves no cgroups behind...", name), t, func() {
+		before := containerCGroups(t)
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		So(runC(CBaseDir, "64", "1000", t), ShouldContainSubstring, "Time Limit Exceeded")
+		So(containerCGroups(t), ShouldResemble, before)
+	})
+}
+
+func TestC0044CGroupGC(t *testing.T) {
+	Convey("Testing clike_container gc...", t, func() {
+		orphan := "00000000-0000-4000-8000-000000000000"
+		dir := "/sys/fs/cgroup/pids/" + orphan
+		if sandbox.IsCGroupV2() {
+			dir = "/sys/fs/cgroup/" + orphan
+		}
+		So(os.Mkdir(dir, 0755), ShouldBeNil)
+
+		// too young to be swept by default
+		output, err := exec.Command("/opt/justice-sandbox/bin/clike_container", "gc").Output()
+		So(err, ShouldBeNil)
+		So(string(output), ShouldNotContainSubstring, orphan)
+
+		output, err = exec.Command("/opt/justice-sandbox/bin/clike_container", "gc", "-min-age=0s").Output()
+		So(err, ShouldBeNil)
+		So(string(output), ShouldContainSubstring, orphan)
+		_, err = os.Stat(dir)
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+}
+
+func TestC0045EscapeProcessGroup(t *testing.T) {
+	name := "escape.c"
+	Convey(fmt.Sprintf("Testing [%s] leaves no process behind...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		// only justiceInit is seen besides /Main, the process left by the first test case is gone
+		output := runCWithFlags(CBaseDir, "", "", []string{"-cases=" + CProjectDir + "/resources/cases/escape.json", "-proc"}, t)
+		So(output, ShouldStartWith, `[{"runtime"`)
+		So(output, ShouldNotContainSubstring, `"status":5`)
+	})
+}
+
+func TestC0046DebugSnapshot(t *testing.T) {
+	name := "infinite_loop.c"
+	Convey(fmt.Sprintf("Testing [%s] in debug mode...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		output := runCWithFlags(CBaseDir, "", "", []string{"-debug"}, t)
+		r := new(model.Result)
+		So(json.Unmarshal([]byte(output), r), ShouldBeNil)
+		So(r.Status, ShouldEqual, model.StatusTle)
+		So(r.Diagnostic, ShouldNotBeNil)
+		So(r.Diagnostic.Limit, ShouldEqual, "timeout")
+		So(r.Diagnostic.Error, ShouldBeEmpty)
+		So(r.Diagnostic.Tasks, ShouldHaveLength, 1)
+		So(r.Diagnostic.Tasks[0].Status, ShouldContainSubstring, "Name:\tMain")
+		So(r.Diagnostic.Tasks[0].Maps, ShouldContainSubstring, "/Main")
+
+		// nothing is recorded without -debug
+		So(runCWithFlags(CBaseDir, "", "", []string{}, t), ShouldNotContainSubstring, "diagnostic")
+	})
+}
+
+func TestC0047Loopback(t *testing.T) {
+	name := "loopback.c"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyCSourceFile(name, t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		// lo stays down by default
+		So(runCWithFlags(CBaseDir, "", "ok", []string{}, t), ShouldContainSubstring, `"status":2`)
+		So(runCWithFlags(CBaseDir, "", "ok", []string{"-loopback"}, t), ShouldContainSubstring, `"status":0`)
+	})
+}
+
+func TestC0048Companion(t *testing.T) {
+	name := "echo_client.c"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyCSourceFile(name, t)
+		companion := compileHelper("companion", "echo_server.c", t)
+
+		So(compileC(name, CBaseDir, t), ShouldBeEmpty)
+		flags := []string{"-companion=" + companion, "-companion-port=10086"}
+		So(runCWithFlags(CBaseDir, "hello", "hello", flags, t), ShouldContainSubstring, `"status":0`)
+		// every container brings a network namespace and a companion of its own
+		So(runCWithFlags(CBaseDir, "world", "world", flags, t), ShouldContainSubstring, `"status":0`)
+		So(runCWithFlags(CBaseDir, "hello", "", []string{}, t), ShouldContainSubstring, `"status":2`)
+	})
+}
@@ -2,12 +2,14 @@ package test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
 	"testing"
 
+	"github.com/justice-oj/sandbox/model"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -37,7 +39,7 @@ func copyCPPSourceFile(name string, t *testing.T) {
 func compileCPP(name, baseDir string, t *testing.T) string {
 	t.Logf("Compiling file %s ...", name)
 
-	var stderr bytes.Buffer
+	var stdout bytes.Buffer
 	args := []string{
 		"-compiler=/usr/bin/g++",
 		"-basedir=" + baseDir,
@@ -46,12 +48,12 @@ func compileCPP(name, baseDir string, t *testing.T) string {
 		"-std=gnu++14",
 	}
 	cmd := exec.Command("/opt/justice-sandbox/bin/clike_compiler", args...)
-	cmd.Stderr = &stderr
+	cmd.Stdout = &stdout
 	if err := cmd.Run(); err != nil {
 		t.Errorf("Invoke `/opt/justice-sandbox/bin/clike_compiler %s` err: %v", strings.Join(args, " "), err)
 	}
 
-	return stderr.String()
+	return compileErrorOf(stdout.Bytes(), t)
 }
 
 // run binary in our container
@@ -77,6 +79,29 @@ func runCPP(baseDir, memory, timeout string, t *testing.T) string {
 	return stdout.String()
 }
 
+// run binary in our container with extra flags of clike_container
+func runCPPWithFlags(baseDir string, flags []string, t *testing.T) string {
+	t.Logf("Running file /Main with %s ...", strings.Join(flags, " "))
+
+	var stdout, stderr bytes.Buffer
+	args := append([]string{
+		"-basedir=" + baseDir,
+		"-input=10:10:23AM",
+		"-expected=10:10:23",
+		"-memory=64",
+		"-timeout=1000",
+	}, flags...)
+	cmd := exec.Command("/opt/justice-sandbox/bin/clike_container", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Invoke `/opt/justice-sandbox/bin/clike_container %s` err: %v", strings.Join(args, " "), err)
+	}
+
+	t.Logf("stderr of runCPPWithFlags: %s", stderr.String())
+	return stdout.String()
+}
+
 func TestCPP0000Fixture(t *testing.T) {
 	CPPProjectDir, _ = os.Getwd()
 	CPPBaseDir = t.TempDir()
@@ -97,7 +122,7 @@ func TestCPP0002CompilerBomb1(t *testing.T) {
 	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
 		copyCPPSourceFile(name, t)
 
-		So(compileCPP(name, CPPBaseDir, t), ShouldContainSubstring, "signal: killed")
+		So(compileCPP(name, CPPBaseDir, t), ShouldContainSubstring, "Compiler Memory Exceeded")
 	})
 }
 
@@ -124,7 +149,9 @@ func TestCPP0005CompilerBomb4(t *testing.T) {
 	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
 		copyCPPSourceFile(name, t)
 
-		So(compileCPP(name, CPPBaseDir, t), ShouldContainSubstring, "signal: killed")
+		// whichever limit is hit first stops the compiler
+		output := compileCPP(name, CPPBaseDir, t)
+		So(strings.HasPrefix(output, "Compile Timeout") || strings.HasPrefix(output, "Compiler Memory Exceeded"), ShouldBeTrue)
 	})
 }
 
@@ -135,7 +162,9 @@ func TestCPP0006CoreDump0(t *testing.T) {
 
 		So(compileCPP(name, CPPBaseDir, t), ShouldBeEmpty)
 		// terminate called after throwing an instance of 'char const*'
-		So(runCPP(CPPBaseDir, "64", "1000", t), ShouldContainSubstring, "Runtime Error")
+		output := runCPP(CPPBaseDir, "64", "1000", t)
+		So(output, ShouldContainSubstring, "Runtime Error")
+		So(output, ShouldContainSubstring, `"signal":"SIGABRT"`)
 	})
 }
 
@@ -145,7 +174,7 @@ func TestCPP0007ForkBomb(t *testing.T) {
 		copyCPPSourceFile(name, t)
 
 		So(compileCPP(name, CPPBaseDir, t), ShouldBeEmpty)
-		So(runCPP(CPPBaseDir, "64", "1000", t), ShouldContainSubstring, "Runtime Error")
+		So(runCPP(CPPBaseDir, "64", "1000", t), ShouldContainSubstring, "Time Limit Exceeded")
 	})
 }
 
@@ -164,7 +193,7 @@ func TestCPP0009InfiniteLoop(t *testing.T) {
 		copyCPPSourceFile(name, t)
 
 		So(compileCPP(name, CPPBaseDir, t), ShouldBeEmpty)
-		So(runCPP(CPPBaseDir, "64", "1000", t), ShouldContainSubstring, "Runtime Error")
+		So(runCPP(CPPBaseDir, "64", "1000", t), ShouldContainSubstring, "Time Limit Exceeded")
 	})
 }
 
@@ -174,7 +203,7 @@ func TestCPP0010MemoryAllocation(t *testing.T) {
 		copyCPPSourceFile(name, t)
 
 		So(compileCPP(name, CPPBaseDir, t), ShouldBeEmpty)
-		So(runCPP(CPPBaseDir, "64", "1000", t), ShouldContainSubstring, "Runtime Error")
+		So(runCPP(CPPBaseDir, "64", "1000", t), ShouldContainSubstring, "Memory Limit Exceeded")
 	})
 }
 
@@ -196,3 +225,32 @@ func TestCPP0012RunCommandLine0(t *testing.T) {
 		So(runCPP(CPPBaseDir, "16", "1000", t), ShouldContainSubstring, `"status":5`)
 	})
 }
+
+func TestCPP0013ThreadPool(t *testing.T) {
+	name := "thread_pool.cpp"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyCPPSourceFile(name, t)
+
+		So(compileCPP(name, CPPBaseDir, t), ShouldBeEmpty)
+		// 80 threads do not fit into the default pids.max of 64
+		So(runCPPWithFlags(CPPBaseDir, []string{}, t), ShouldContainSubstring, "Runtime Error")
+		So(runCPPWithFlags(CPPBaseDir, []string{"-pids=128", "-cpu-quota=100000"}, t), ShouldContainSubstring, `"status":0`)
+		So(runCPPWithFlags(CPPBaseDir, []string{"-pids=-1", "-cpu-quota=-1"}, t), ShouldContainSubstring, `"status":0`)
+	})
+}
+
+func TestCPP0014DiagnosticsLimit(t *testing.T) {
+	name := "compiler_bomb_2.cpp"
+	Convey(fmt.Sprintf("Testing [%s] with diagnostics limit...", name), t, func() {
+		copyCPPSourceFile(name, t)
+
+		args := []string{"-compiler=/usr/bin/g++", "-basedir=" + CPPBaseDir, "-filename=Main.cpp", "-std=gnu++14", "-diagnostics-limit=1"}
+		output, err := exec.Command("/opt/justice-sandbox/bin/clike_compiler", args...).Output()
+		So(err, ShouldBeNil)
+		r := new(model.CompileResult)
+		So(json.Unmarshal(output, r), ShouldBeNil)
+		So(r.Status, ShouldEqual, model.CompileStatusError)
+		So(len(r.Diagnostics), ShouldBeLessThan, 1100)
+		So(r.Diagnostics, ShouldEndWith, "... (truncated)\n")
+	})
+}
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	GoBaseDir    string
+	GoProjectDir string
+)
+
+// copy test source file `*.go` to tmp dir
+func copyGoSourceFile(name string, t *testing.T) {
+	t.Logf("Copying file %s ...", name)
+	if err := os.MkdirAll(GoBaseDir, os.ModePerm); err != nil {
+		t.Errorf("Invoke mkdir(%s) err: %v", GoBaseDir, err.Error())
+	}
+
+	args := []string{
+		GoProjectDir + "/resources/go/" + name,
+		GoBaseDir + "/Main.go",
+	}
+	cmd := exec.Command("cp", args...)
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Invoke `cp %s` err: %v", strings.Join(args, " "), err)
+	}
+}
+
+// compile Go source file with the go profile in profiles/languages.json
+func compileGo(name, baseDir string, t *testing.T) string {
+	t.Logf("Compiling file %s ...", name)
+
+	var stdout bytes.Buffer
+	args := []string{
+		"-basedir=" + baseDir,
+		"-timeout=60000",
+		// the standard library is built into a fresh GOCACHE under /tmp every time
+		"-memory=1024",
+		"-tmp-size=512",
+		"-language=go",
+		"-languages=" + GoProjectDir + "/../profiles/languages.json",
+	}
+	cmd := exec.Command("/opt/justice-sandbox/bin/clike_compiler", args...)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Invoke `/opt/justice-sandbox/bin/clike_compiler %s` err: %v", strings.Join(args, " "), err)
+	}
+
+	return compileErrorOf(stdout.Bytes(), t)
+}
+
+// run binary in our container with the run command of the go profile
+func runGo(baseDir, memory, timeout string, t *testing.T) string {
+	t.Log("Running binary /Main ...")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{
+		"-basedir=" + baseDir,
+		"-input=10:10:23PM",
+		"-expected=22:10:23",
+		"-memory=" + memory,
+		"-timeout=" + timeout,
+		"-language=go",
+		"-languages=" + GoProjectDir + "/../profiles/languages.json",
+	}
+	cmd := exec.Command("/opt/justice-sandbox/bin/clike_container", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Invoke `/opt/justice-sandbox/bin/clike_container %s` err: %v", strings.Join(args, " "), err)
+	}
+
+	t.Logf("stderr of runGo: %s", stderr.String())
+	return stdout.String()
+}
+
+func TestGo0000Fixture(t *testing.T) {
+	GoProjectDir, _ = os.Getwd()
+	GoBaseDir = t.TempDir()
+}
+
+func TestGo0001AC(t *testing.T) {
+	name := "ac.go"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyGoSourceFile(name, t)
+
+		So(compileGo(name, GoBaseDir, t), ShouldBeEmpty)
+		So(runGo(GoBaseDir, "64", "2000", t), ShouldContainSubstring, `"status":0`)
+	})
+}
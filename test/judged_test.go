@@ -0,0 +1,193 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/justice-oj/sandbox/model"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// start judged listening on a unix socket in tmp dir, which is stopped along with the test
+func startJudged(t *testing.T, flags ...string) *http.Client {
+	t.Log("Starting judged ...")
+
+	socket := t.TempDir() + "/judged.sock"
+	cmd := exec.Command("/opt/justice-sandbox/bin/judged", append([]string{"-listen=unix:" + socket, "-workers=2"}, flags...)...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Invoke `/opt/justice-sandbox/bin/judged` err: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			return &http.Client{
+				Transport: &http.Transport{
+					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+						return new(net.Dialer).DialContext(ctx, "unix", socket)
+					},
+				},
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("judged did not listen on %s", socket)
+	return nil
+}
+
+// submit C source file `*.c` to judged
+func submitC(client *http.Client, name string, cases []model.TestCase, t *testing.T) *model.JobResult {
+	t.Logf("Submitting file %s ...", name)
+
+	result := new(model.JobResult)
+	source, err := ioutil.ReadFile(CProjectDir + "/resources/c/" + name)
+	if err != nil {
+		t.Errorf("Invoke ioutil.ReadFile(%s) err: %v", name, err)
+		return result
+	}
+
+	job, _ := json.Marshal(model.Job{
+		Source:  string(source),
+		Timeout: 1000,
+		Memory:  64,
+		Cases:   cases,
+	})
+	resp, err := client.Post("http://judged/judge", "application/json", bytes.NewReader(job))
+	if err != nil {
+		t.Errorf("Invoke POST /judge err: %v", err)
+		return result
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("POST /judge returned %d: %s", resp.StatusCode, body)
+		return result
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		t.Errorf("Invoke json.Unmarshal(%s) err: %v", body, err)
+	}
+	return result
+}
+
+func TestJudged0000Fixture(t *testing.T) {
+	CProjectDir, _ = os.Getwd()
+}
+
+func TestJudged0001AC(t *testing.T) {
+	name := "ac.c"
+	client := startJudged(t)
+	Convey(fmt.Sprintf("Testing [%s] through judged...", name), t, func() {
+		result := submitC(client, name, []model.TestCase{
+			{Input: "10:10:23PM", Expected: "22:10:23"},
+			{Input: "12:00:00AM", Expected: "00:00:00"},
+		}, t)
+
+		So(result.Compile.Status, ShouldEqual, model.CompileStatusOk)
+		So(len(result.Results), ShouldEqual, 2)
+		So(result.Results[0].Status, ShouldEqual, model.StatusAc)
+		So(result.Results[1].Status, ShouldEqual, model.StatusAc)
+	})
+}
+
+func TestJudged0002CompileError(t *testing.T) {
+	name := "plain_text.c"
+	client := startJudged(t)
+	Convey(fmt.Sprintf("Testing [%s] through judged...", name), t, func() {
+		result := submitC(client, name, []model.TestCase{{Input: "", Expected: ""}}, t)
+
+		So(result.Compile.Status, ShouldEqual, model.CompileStatusError)
+		So(result.Compile.Diagnostics, ShouldContainSubstring, "error")
+		So(result.Results, ShouldBeEmpty)
+	})
+}
+
+func TestJudged0003Concurrent(t *testing.T) {
+	name := "ac.c"
+	client := startJudged(t)
+	Convey(fmt.Sprintf("Testing [%s] through judged concurrently...", name), t, func() {
+		var wg sync.WaitGroup
+		results := make([]*model.JobResult, 4)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = submitC(client, name, []model.TestCase{{Input: "10:10:23PM", Expected: "22:10:23"}}, t)
+			}(i)
+		}
+		wg.Wait()
+
+		for _, result := range results {
+			So(len(result.Results), ShouldEqual, 1)
+			So(result.Results[0].Status, ShouldEqual, model.StatusAc)
+		}
+	})
+}
+
+func TestJudged0004InvalidJob(t *testing.T) {
+	client := startJudged(t)
+	Convey("Testing invalid jobs through judged...", t, func() {
+		cases := []model.TestCase{{Input: "", Expected: ""}}
+		for _, job := range []model.Job{
+			// judged must not run a binary of the client's choosing, neither as compiler nor as special judge
+			{Source: "int main() {}", Compiler: "/bin/sh", Cases: cases},
+			{Source: "int main() {}", Language: "c", Compiler: "/usr/bin/gcc", Cases: cases},
+			{Source: "int main() {}", Checker: "spj", Cases: cases},
+			{Source: "int main() {}", Filename: "../Main.c", Cases: cases},
+			// nor hand it a host file as stdin
+			{Source: "int main() {}", Cases: []model.TestCase{{InputFile: "/etc/shadow", Expected: ""}}},
+			{Source: "int main() {}", Cases: []model.TestCase{{InputFile: "-", Expected: ""}}},
+		} {
+			body, _ := json.Marshal(job)
+			resp, err := client.Post("http://judged/judge", "application/json", bytes.NewReader(body))
+			So(err, ShouldBeNil)
+			_ = resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestJudged0005Caps(t *testing.T) {
+	client := startJudged(t, "-max-job-size=1", "-max-timeout=500")
+	Convey("Testing the caps of judged...", t, func() {
+		// no job without a slot to run it in
+		So(exec.Command("/opt/justice-sandbox/bin/judged", "-listen=unix:"+t.TempDir()+"/judged.sock", "-workers=0").Run(), ShouldNotBeNil)
+
+		cases := []model.TestCase{{Input: "", Expected: ""}}
+		body, _ := json.Marshal(model.Job{Source: string(bytes.Repeat([]byte("/**/"), 1024*1024)), Cases: cases})
+		resp, err := client.Post("http://judged/judge", "application/json", bytes.NewReader(body))
+		So(err, ShouldBeNil)
+		_ = resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+
+		// the wall time limit asked for is cut down to -max-timeout
+		source, err := ioutil.ReadFile(CProjectDir + "/resources/c/infinite_loop.c")
+		So(err, ShouldBeNil)
+		body, _ = json.Marshal(model.Job{Source: string(source), Timeout: 60000, Memory: 64, Cases: cases})
+		startTime := time.Now()
+		resp, err = client.Post("http://judged/judge", "application/json", bytes.NewReader(body))
+		So(err, ShouldBeNil)
+		defer func() { _ = resp.Body.Close() }()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		result := new(model.JobResult)
+		So(json.NewDecoder(resp.Body).Decode(result), ShouldBeNil)
+		So(result.Results, ShouldHaveLength, 1)
+		So(result.Results[0].Status, ShouldEqual, model.StatusTle)
+		So(time.Since(startTime), ShouldBeLessThan, 10*time.Second)
+	})
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	PythonBaseDir    string
+	PythonProjectDir string
+)
+
+// copy test source file `*.py` to tmp dir
+func copyPythonSourceFile(name string, t *testing.T) {
+	t.Logf("Copying file %s ...", name)
+	if err := os.MkdirAll(PythonBaseDir, os.ModePerm); err != nil {
+		t.Errorf("Invoke mkdir(%s) err: %v", PythonBaseDir, err.Error())
+	}
+
+	args := []string{
+		PythonProjectDir + "/resources/python/" + name,
+		PythonBaseDir + "/Main.py",
+	}
+	cmd := exec.Command("cp", args...)
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Invoke `cp %s` err: %v", strings.Join(args, " "), err)
+	}
+}
+
+// compile Python source file with the python profile in profiles/languages.json
+func compilePython(name, baseDir string, t *testing.T) string {
+	t.Logf("Compiling file %s ...", name)
+
+	var stdout bytes.Buffer
+	args := []string{
+		"-basedir=" + baseDir,
+		"-timeout=5000",
+		"-language=python",
+		"-languages=" + PythonProjectDir + "/../profiles/languages.json",
+	}
+	cmd := exec.Command("/opt/justice-sandbox/bin/clike_compiler", args...)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Invoke `/opt/justice-sandbox/bin/clike_compiler %s` err: %v", strings.Join(args, " "), err)
+	}
+
+	return compileErrorOf(stdout.Bytes(), t)
+}
+
+// run Main.py in our container with the run command and runtime dirs of the python profile
+func runPython(baseDir string, flags []string, t *testing.T) string {
+	t.Log("Running Main.py ...")
+
+	var stdout, stderr bytes.Buffer
+	args := append([]string{
+		"-basedir=" + baseDir,
+		"-input=10:10:23PM",
+		"-expected=22:10:23",
+		"-memory=64",
+		"-timeout=2000",
+		"-language=python",
+		"-languages=" + PythonProjectDir + "/../profiles/languages.json",
+	}, flags...)
+	cmd := exec.Command("/opt/justice-sandbox/bin/clike_container", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Invoke `/opt/justice-sandbox/bin/clike_container %s` err: %v", strings.Join(args, " "), err)
+	}
+
+	t.Logf("stderr of runPython: %s", stderr.String())
+	return stdout.String()
+}
+
+func TestPython0000Fixture(t *testing.T) {
+	PythonProjectDir, _ = os.Getwd()
+	PythonBaseDir = t.TempDir()
+}
+
+func TestPython0001AC(t *testing.T) {
+	name := "ac.py"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyPythonSourceFile(name, t)
+
+		So(compilePython(name, PythonBaseDir, t), ShouldBeEmpty)
+		So(runPython(PythonBaseDir, nil, t), ShouldContainSubstring, `"status":0`)
+		So(runPython(PythonBaseDir, []string{"-overlay"}, t), ShouldContainSubstring, `"status":0`)
+		// the runtime dirs imply -overlay, none of their mount points is left in basedir
+		for _, dir := range []string{"usr", "bin", "lib", "lib64"} {
+			_, err := os.Lstat(PythonBaseDir + "/" + dir)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		}
+	})
+}
+
+func TestPython0002HostFile(t *testing.T) {
+	name := "host_file.py"
+	Convey(fmt.Sprintf("Testing [%s]...", name), t, func() {
+		copyPythonSourceFile(name, t)
+
+		So(compilePython(name, PythonBaseDir, t), ShouldBeEmpty)
+		// FileNotFoundError on stderr
+		So(runPython(PythonBaseDir, nil, t), ShouldContainSubstring, "Runtime Error")
+	})
+}
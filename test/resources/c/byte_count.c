@@ -0,0 +1,13 @@
+#include <stdio.h>
+
+int main() {
+    char buf[4096];
+    size_t n;
+    long long total = 0;
+
+    while ((n = fread(buf, 1, sizeof(buf), stdin)) > 0) {
+        total += n;
+    }
+    printf("%lld\n", total);
+    return 0;
+}
@@ -0,0 +1,34 @@
+#include <stdio.h>
+#include <string.h>
+#include <unistd.h>
+#include <netinet/in.h>
+#include <arpa/inet.h>
+#include <sys/socket.h>
+
+// sends a line of input to the companion on 127.0.0.1:10086 and prints whatever comes back
+int main() {
+    struct sockaddr_in addr;
+    char line[64] = {0}, reply[64] = {0};
+    int sock;
+
+    if (scanf("%63s", line) != 1) {
+        return 1;
+    }
+
+    memset(&addr, 0, sizeof(addr));
+    addr.sin_family = AF_INET;
+    addr.sin_port = htons(10086);
+    addr.sin_addr.s_addr = inet_addr("127.0.0.1");
+
+    sock = socket(AF_INET, SOCK_STREAM, 0);
+    if (sock < 0 || connect(sock, (struct sockaddr *) &addr, sizeof(addr)) < 0) {
+        printf("connect error");
+        return 1;
+    }
+    write(sock, line, strlen(line));
+    shutdown(sock, SHUT_WR);
+    read(sock, reply, sizeof(reply) - 1);
+
+    printf("%s", reply);
+    return 0;
+}
@@ -0,0 +1,30 @@
+#include <ctype.h>
+#include <stdlib.h>
+#include <dirent.h>
+#include <stdio.h>
+#include <unistd.h>
+
+// prints how many other processes are alive, then leaves one behind which escapes the process group
+int main() {
+    int others = 0;
+    DIR *proc = opendir("/proc");
+    struct dirent *entry;
+    while (proc != NULL && (entry = readdir(proc)) != NULL) {
+        if (isdigit(entry->d_name[0]) && atoi(entry->d_name) != getpid()) {
+            others++;
+        }
+    }
+    printf("%d\n", others);
+    fflush(stdout);
+
+    if (fork() == 0) {
+        setsid();
+        close(0);
+        close(1);
+        close(2);
+        while (1) {
+            sleep(1);
+        }
+    }
+    return 0;
+}
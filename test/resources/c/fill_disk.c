@@ -0,0 +1,22 @@
+#include <stdio.h>
+#include <string.h>
+
+int main() {
+    char buf[4096];
+    memset(buf, 'x', sizeof(buf));
+
+    FILE *f = fopen("/big", "w");
+    if (f == NULL) {
+        puts("read-only");
+        return 0;
+    }
+    // 64MB at most
+    for (int i = 0; i < 16384; i++) {
+        if (fwrite(buf, 1, sizeof(buf), f) != sizeof(buf) || fflush(f) != 0) {
+            puts("full");
+            return 0;
+        }
+    }
+    puts("written");
+    return 0;
+}
@@ -0,0 +1,8 @@
+#include <stdio.h>
+
+int main() {
+    int a, b;
+    scanf("%d %d", &a, &b);
+    printf("%.9f\n", (double) a / b);
+    return 0;
+}
@@ -0,0 +1,21 @@
+#include <stdio.h>
+
+int main() {
+    int lo = 1, hi = 1000000, mid;
+    char reply[2];
+
+    while (lo <= hi) {
+        mid = (lo + hi) / 2;
+        printf("%d\n", mid);
+        fflush(stdout);
+        if (scanf("%1s", reply) != 1 || reply[0] == '=') {
+            break;
+        }
+        if (reply[0] == '<') {
+            lo = mid + 1;
+        } else {
+            hi = mid - 1;
+        }
+    }
+    return 0;
+}
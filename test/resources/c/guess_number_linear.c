@@ -0,0 +1,15 @@
+#include <stdio.h>
+
+int main() {
+    int i;
+    char reply[2];
+
+    for (i = 1; i <= 1000000; i++) {
+        printf("%d\n", i);
+        fflush(stdout);
+        if (scanf("%1s", reply) != 1 || reply[0] == '=') {
+            break;
+        }
+    }
+    return 0;
+}
@@ -0,0 +1 @@
+#include </etc/passwd>
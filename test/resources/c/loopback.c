@@ -0,0 +1,37 @@
+#include <stdio.h>
+#include <string.h>
+#include <unistd.h>
+#include <netinet/in.h>
+#include <arpa/inet.h>
+#include <sys/socket.h>
+
+// connects to a listening socket of its own on 127.0.0.1, which needs lo to be up
+int main() {
+    struct sockaddr_in addr;
+    socklen_t len = sizeof(addr);
+    char buf[8] = {0};
+    int server, client, conn;
+
+    memset(&addr, 0, sizeof(addr));
+    addr.sin_family = AF_INET;
+    addr.sin_addr.s_addr = inet_addr("127.0.0.1");
+
+    server = socket(AF_INET, SOCK_STREAM, 0);
+    if (server < 0 || bind(server, (struct sockaddr *) &addr, sizeof(addr)) < 0 || listen(server, 1) < 0) {
+        printf("listen error");
+        return 1;
+    }
+    getsockname(server, (struct sockaddr *) &addr, &len);
+
+    client = socket(AF_INET, SOCK_STREAM, 0);
+    if (client < 0 || connect(client, (struct sockaddr *) &addr, sizeof(addr)) < 0) {
+        printf("connect error");
+        return 1;
+    }
+    conn = accept(server, NULL, NULL);
+    write(client, "ok", 2);
+    read(conn, buf, sizeof(buf) - 1);
+
+    printf("%s", buf);
+    return 0;
+}
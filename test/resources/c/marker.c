@@ -0,0 +1,18 @@
+#include <stdio.h>
+#include <unistd.h>
+
+int main() {
+    if (access("/marker", F_OK) == 0) {
+        puts("dirty");
+        return 0;
+    }
+
+    FILE *f = fopen("/marker", "w");
+    if (f == NULL) {
+        puts("read-only");
+        return 0;
+    }
+    fclose(f);
+    puts("clean");
+    return 0;
+}
@@ -0,0 +1,21 @@
+#include <linux/capability.h>
+#include <stdio.h>
+#include <sys/prctl.h>
+#include <sys/syscall.h>
+#include <unistd.h>
+
+// prints the identity of /Main and what is left of its privileges
+int main() {
+    struct __user_cap_header_struct header = {_LINUX_CAPABILITY_VERSION_3, 0};
+    struct __user_cap_data_struct data[2];
+    if (syscall(SYS_capget, &header, data) != 0) {
+        puts("capget failed");
+        return 0;
+    }
+
+    unsigned caps = data[0].effective | data[0].permitted | data[0].inheritable
+                    | data[1].effective | data[1].permitted | data[1].inheritable;
+    printf("uid %d gid %d caps %u bounding %d nnp %d\n", getuid(), getgid(), caps,
+           prctl(PR_CAPBSET_READ, CAP_SYS_ADMIN, 0, 0, 0), prctl(PR_GET_NO_NEW_PRIVS, 0, 0, 0, 0));
+    return 0;
+}
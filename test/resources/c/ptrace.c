@@ -0,0 +1,9 @@
+#include <stdio.h>
+#include <sys/ptrace.h>
+
+int main() {
+    if (ptrace(PTRACE_TRACEME, 0, NULL, NULL) == -1) {
+        puts("ptrace failed");
+    }
+    return 0;
+}
@@ -0,0 +1,6 @@
+#include <stdio.h>
+
+int main() {
+    printf("1   2\n\n3\t4\n");
+    return 0;
+}
@@ -0,0 +1,18 @@
+#include <fcntl.h>
+#include <stdio.h>
+#include <unistd.h>
+
+// prints which of /dev, /tmp and /proc are usable, - for those which are not
+int main() {
+    char buf[16];
+    int fd = open("/dev/urandom", O_RDONLY);
+    int dev = fd >= 0 && read(fd, buf, sizeof(buf)) == sizeof(buf) && access("/dev/sda", F_OK) != 0;
+
+    FILE *f = fopen("/tmp/justice", "w");
+    int tmp = f != NULL && fputs("justice", f) >= 0 && fclose(f) == 0;
+
+    int proc = access("/proc/self/status", R_OK) == 0;
+
+    printf("%s %s %s\n", dev ? "dev" : "-", tmp ? "tmp" : "-", proc ? "proc" : "-");
+    return 0;
+}
@@ -0,0 +1,6 @@
+#include <stdio.h>
+
+int main() {
+    puts("YES");
+    return 0;
+}
@@ -0,0 +1,34 @@
+#include <string.h>
+#include <unistd.h>
+#include <netinet/in.h>
+#include <arpa/inet.h>
+#include <sys/socket.h>
+
+// usage: echo_server, echoes every connection on 127.0.0.1:10086 until it is killed
+int main() {
+    struct sockaddr_in addr;
+    char buf[64];
+    int server, conn, one = 1;
+    ssize_t n;
+
+    memset(&addr, 0, sizeof(addr));
+    addr.sin_family = AF_INET;
+    addr.sin_port = htons(10086);
+    addr.sin_addr.s_addr = inet_addr("127.0.0.1");
+
+    server = socket(AF_INET, SOCK_STREAM, 0);
+    setsockopt(server, SOL_SOCKET, SO_REUSEADDR, &one, sizeof(one));
+    if (server < 0 || bind(server, (struct sockaddr *) &addr, sizeof(addr)) < 0 || listen(server, 8) < 0) {
+        return 1;
+    }
+
+    for (;;) {
+        if ((conn = accept(server, NULL, NULL)) < 0) {
+            continue;
+        }
+        while ((n = read(conn, buf, sizeof(buf))) > 0) {
+            write(conn, buf, n);
+        }
+        close(conn);
+    }
+}
@@ -0,0 +1,17 @@
+#include <chrono>
+#include <iostream>
+#include <thread>
+#include <vector>
+
+using namespace std;
+
+int main() {
+    vector<thread> pool;
+    for (int i = 0; i < 80; i++) {
+        pool.emplace_back([] { this_thread::sleep_for(chrono::milliseconds(100)); });
+    }
+    for (auto &t : pool) {
+        t.join();
+    }
+    cout << "10:10:23" << endl;
+}
@@ -0,0 +1,21 @@
+// +build ignore
+
+package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	var hh, mm, ss int
+	var tt string
+
+	_, _ = fmt.Scanf("%d:%d:%d%s", &hh, &mm, &ss, &tt)
+	if tt == "PM" && hh != 12 {
+		hh += 12
+	}
+	if tt == "AM" && hh == 12 {
+		hh = 0
+	}
+	fmt.Printf("%02d:%02d:%02d", hh, mm, ss)
+}
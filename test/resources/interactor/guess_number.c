@@ -0,0 +1,25 @@
+#include <stdio.h>
+
+// usage: interactor <input> <expected>, the secret number in [1, 1000000] is read from input.
+// answers "<", ">" or "=" to every guess on stdin, at most 20 guesses are allowed
+int main(int argc, char *argv[]) {
+    int secret, guess, i;
+    FILE *in = fopen(argv[1], "r");
+    if (in == NULL || fscanf(in, "%d", &secret) != 1) {
+        return 2;
+    }
+
+    for (i = 0; i < 20; i++) {
+        if (scanf("%d", &guess) != 1) {
+            return 1;
+        }
+        if (guess == secret) {
+            puts("=");
+            fflush(stdout);
+            return 0;
+        }
+        puts(guess < secret ? "<" : ">");
+        fflush(stdout);
+    }
+    return 1;
+}
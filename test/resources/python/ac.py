@@ -0,0 +1,10 @@
+import sys
+
+hh, mm, rest = sys.stdin.readline().strip().split(":")
+ss, tt = rest[:2], rest[2:]
+hh = int(hh)
+if tt == "PM" and hh != 12:
+    hh += 12
+if tt == "AM" and hh == 12:
+    hh = 0
+print("%02d:%s:%s" % (hh, mm, ss))
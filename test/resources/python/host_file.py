@@ -0,0 +1,2 @@
+# only the runtime dirs of the profile are there, the rest of the host is not
+open("/etc/passwd").read()
@@ -0,0 +1,15 @@
+#include <stdio.h>
+#include <strings.h>
+
+// usage: spj <input> <output> <expected>, accepts the first token of output in any case
+int main(int argc, char *argv[]) {
+    char output[64] = "", expected[64] = "";
+    FILE *o = fopen(argv[2], "r"), *e = fopen(argv[3], "r");
+    if (o == NULL || e == NULL) {
+        return 2;
+    }
+
+    fscanf(o, "%63s", output);
+    fscanf(e, "%63s", expected);
+    return strcasecmp(output, expected) == 0 ? 0 : 1;
+}
@@ -0,0 +1,135 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/justice-oj/sandbox/model"
+	"github.com/justice-oj/sandbox/sandbox/runner"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// package runner re-executes this test binary as justiceCompile and justiceInit
+var RunnerBaseDir string
+
+// copy test source file `*.c` to tmp dir and compile it through runner.Compile
+func compileWithRunner(name string, t *testing.T) *model.CompileResult {
+	t.Logf("Compiling file %s with runner.Compile ...", name)
+
+	if err := os.MkdirAll(RunnerBaseDir, os.ModePerm); err != nil {
+		t.Errorf("Invoke mkdir(%s) err: %v", RunnerBaseDir, err)
+	}
+	source, err := ioutil.ReadFile(CProjectDir + "/resources/c/" + name)
+	if err != nil {
+		t.Errorf("Invoke ioutil.ReadFile(%s) err: %v", name, err)
+	}
+	if err := ioutil.WriteFile(RunnerBaseDir+"/Main.c", source, 0644); err != nil {
+		t.Errorf("Invoke ioutil.WriteFile(%s) err: %v", name, err)
+	}
+
+	spec := runner.DefaultCompileSpec(RunnerBaseDir)
+	spec.Command = []string{"/usr/bin/gcc", "Main.c", "-std=gnu11", "-static", "-o", "Main"}
+	spec.Timeout = 3000
+	spec.Artifact = "Main"
+	r, err := runner.Compile(context.Background(), spec)
+	if err != nil {
+		t.Errorf("Invoke runner.Compile() err: %v", err)
+		return new(model.CompileResult)
+	}
+	return r
+}
+
+func runSpec(cases []model.TestCase) runner.RunSpec {
+	spec := runner.DefaultRunSpec(RunnerBaseDir)
+	spec.Cases = cases
+	spec.Timeout = 1000
+	spec.Limits.Memory = 64
+	return spec
+}
+
+func TestRunner0000Fixture(t *testing.T) {
+	CProjectDir, _ = os.Getwd()
+	RunnerBaseDir = t.TempDir()
+}
+
+func TestRunner0001AC(t *testing.T) {
+	name := "ac.c"
+	Convey(fmt.Sprintf("Testing [%s] with runner...", name), t, func() {
+		So(compileWithRunner(name, t).Status, ShouldEqual, model.CompileStatusOk)
+
+		results, err := runner.Run(context.Background(), runSpec([]model.TestCase{
+			{Input: "10:10:23PM", Expected: "22:10:23"},
+			{Input: "12:00:00AM", Expected: "12:00:00"},
+		}))
+		So(err, ShouldBeNil)
+		So(results, ShouldHaveLength, 2)
+		So(results[0].Status, ShouldEqual, model.StatusAc)
+		So(results[1].Status, ShouldEqual, model.StatusWa)
+	})
+}
+
+func TestRunner0002CompileError(t *testing.T) {
+	name := "plain_text.c"
+	Convey(fmt.Sprintf("Testing [%s] with runner...", name), t, func() {
+		r := compileWithRunner(name, t)
+		So(r.Status, ShouldEqual, model.CompileStatusError)
+		So(r.Diagnostics, ShouldContainSubstring, "error")
+	})
+}
+
+func TestRunner0003Canceled(t *testing.T) {
+	name := "infinite_loop.c"
+	Convey(fmt.Sprintf("Testing [%s] with runner until canceled...", name), t, func() {
+		So(compileWithRunner(name, t).Status, ShouldEqual, model.CompileStatusOk)
+
+		before := containerCGroups(t)
+		spec := runSpec([]model.TestCase{{Input: "", Expected: ""}})
+		spec.Timeout = 10000
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		startTime := time.Now()
+		results, err := runner.Run(ctx, spec)
+		So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+		So(results, ShouldBeNil)
+		So(time.Since(startTime), ShouldBeLessThan, 5*time.Second)
+		So(containerCGroups(t), ShouldResemble, before)
+	})
+}
+
+func TestRunner0004BrokenSandbox(t *testing.T) {
+	name := "ac.c"
+	Convey(fmt.Sprintf("Testing [%s] with runner in a broken sandbox...", name), t, func() {
+		So(compileWithRunner(name, t).Status, ShouldEqual, model.CompileStatusOk)
+
+		// justiceInit gives up before the first test case, which is not the fault of /Main
+		spec := runSpec([]model.TestCase{{Input: "10:10:23PM", Expected: "22:10:23"}})
+		spec.Seccomp = RunnerBaseDir + "/no-such-profile.json"
+		results, err := runner.Run(context.Background(), spec)
+		var sandboxErr *runner.Error
+		So(errors.As(err, &sandboxErr), ShouldBeTrue)
+		So(sandboxErr.Op, ShouldEqual, "justiceInit")
+		So(results, ShouldBeNil)
+	})
+}
+
+func TestRunner0005InvalidSpec(t *testing.T) {
+	Convey("Testing zero-valued specs with runner...", t, func() {
+		// nothing is started for a spec the sandbox cannot be set up with
+		_, err := runner.Compile(context.Background(), runner.CompileSpec{Basedir: RunnerBaseDir, Command: []string{"/usr/bin/gcc"}})
+		So(err, ShouldNotBeNil)
+		results, err := runner.Run(context.Background(), runner.RunSpec{Basedir: RunnerBaseDir, Cases: []model.TestCase{{}}})
+		So(err, ShouldNotBeNil)
+		So(results, ShouldBeNil)
+
+		spec := runSpec([]model.TestCase{{}})
+		spec.Limits.CPUPeriod = 0
+		_, err = runner.Run(context.Background(), spec)
+		So(err, ShouldNotBeNil)
+	})
+}